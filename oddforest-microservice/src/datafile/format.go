@@ -0,0 +1,66 @@
+package datafile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// Dataset file formats, detected from a file's contents when it is uploaded. Datasets catalogued before formats were recorded have no format and are CSV.
+const (
+	FormatCSV       = "csv"
+	FormatCSVGzip   = "csv.gz"
+	FormatJSONLines = "jsonl"
+	FormatParquet   = "parquet"
+)
+
+// sniffBytes: how much of a file Detect looks at.
+const sniffBytes = 512
+
+// extensions: file name suffixes trimmed to get a dataset's name, longest first so ".csv.gz" wins over ".gz".
+var extensions = []string{".csv.gz", ".ndjson", ".parquet", ".jsonl", ".csv", ".gz"}
+
+// Detect: works out the format of a dataset file from its first bytes. Parquet files start with the magic "PAR1" and gzip files with 1f 8b;
+// a file whose first non-blank character opens a JSON object is JSON Lines, and anything else is read as CSV.
+func Detect(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, sniffBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("PAR1")):
+		return FormatParquet, nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return FormatCSVGzip, nil
+	case bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n\ufeff"), []byte("{")):
+		return FormatJSONLines, nil
+	}
+	return FormatCSV, nil
+}
+
+// Native: reports whether the server reads a format itself. Parquet datasets are only read by the Python random forest tool.
+func Native(format string) bool {
+	switch format {
+	case "", FormatCSV, FormatCSVGzip, FormatJSONLines:
+		return true
+	}
+	return false
+}
+
+// TrimExtension: a dataset file name without its format's extension, e.g. "churn" for "churn.csv.gz".
+func TrimExtension(filename string) string {
+	for _, extension := range extensions {
+		if len(filename) > len(extension) && strings.EqualFold(filename[len(filename)-len(extension):], extension) {
+			return filename[:len(filename)-len(extension)]
+		}
+	}
+	return filename
+}
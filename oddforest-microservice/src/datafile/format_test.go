@@ -0,0 +1,77 @@
+package datafile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile: writes data to a file in a fresh temporary directory and returns its path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func gzipped(t *testing.T, data string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+	}{
+		{"csv", []byte("x,label\n1,a\n"), FormatCSV},
+		{"csv with a byte order mark", []byte("\ufeffx,label\n1,a\n"), FormatCSV},
+		{"empty file", nil, FormatCSV},
+		{"gzip", gzipped(t, "x,label\n1,a\n"), FormatCSVGzip},
+		{"json lines", []byte(`{"x": 1, "label": "a"}` + "\n"), FormatJSONLines},
+		{"json lines after blank lines and a byte order mark", []byte("\ufeff\n  \r\n" + `{"x": 1}` + "\n"), FormatJSONLines},
+		{"parquet", []byte("PAR1\x15\x04"), FormatParquet},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			format, err := Detect(writeFile(t, "upload", test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != test.format {
+				t.Errorf("format %q, want %q", format, test.format)
+			}
+		})
+	}
+}
+
+func TestTrimExtension(t *testing.T) {
+	tests := map[string]string{
+		"churn.csv":     "churn",
+		"churn.CSV":     "churn",
+		"churn.csv.gz":  "churn",
+		"churn.jsonl":   "churn",
+		"churn.parquet": "churn",
+		"churn.v2.csv":  "churn.v2",
+		"churn":         "churn",
+		".csv":          ".csv",
+	}
+	for filename, want := range tests {
+		if got := TrimExtension(filename); got != want {
+			t.Errorf("TrimExtension(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package datafile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxLineBytes is the longest JSON Lines record Open reads.
+const maxLineBytes = 64 << 20
+
+// byteOrderMark: the UTF-8 byte order mark some editors, Excel among them, write at the start of a file. pandas skips it, and so does Open.
+const byteOrderMark = "\ufeff"
+
+// Reader: reads a dataset file row by row. Every row holds one text cell per column, in the order of Columns; an empty cell is a missing value.
+// Read returns io.EOF after the last row.
+type Reader interface {
+	Columns() []string
+	Read() ([]string, error)
+	Close() error
+}
+
+// Open: opens a dataset file of one of the Native formats for reading.
+func Open(path string, format string) (Reader, error) {
+	switch format {
+	case "", FormatCSV, FormatCSVGzip:
+		return openCSV(path, format == FormatCSVGzip)
+	case FormatJSONLines:
+		return openJSONLines(path)
+	}
+	return nil, fmt.Errorf("dataset %s: %s files are not read by the server", path, format)
+}
+
+// Columns: reads the column names of a dataset file.
+func Columns(path string, format string) ([]string, error) {
+	reader, err := Open(path, format)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return reader.Columns(), nil
+}
+
+// csvReader: a CSV file, optionally gzip-compressed. Column names are trimmed of surrounding spaces.
+// Parsing is as lenient as pandas' read_csv: a stray quote inside an unquoted cell is kept as text, and a row with fewer cells than the header
+// is padded with missing values. A row with more cells than the header is an error.
+type csvReader struct {
+	file    *os.File
+	gzip    *gzip.Reader
+	reader  *csv.Reader
+	path    string
+	line    int
+	columns []string
+}
+
+func openCSV(path string, compressed bool) (*csvReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	self := &csvReader{file: f, path: path, line: 1}
+	var r io.Reader = f
+	if compressed {
+		if self.gzip, err = gzip.NewReader(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("decompressing %s: %w", path, err)
+		}
+		r = self.gzip
+	}
+	self.reader = csv.NewReader(r)
+	self.reader.ReuseRecord = true
+	self.reader.LazyQuotes = true
+	self.reader.FieldsPerRecord = -1
+	header, err := self.reader.Read()
+	if err != nil {
+		self.Close()
+		return nil, fmt.Errorf("reading header of %s: %w", path, err)
+	}
+	header[0] = strings.TrimPrefix(header[0], byteOrderMark)
+	self.columns = make([]string, len(header))
+	for i, name := range header {
+		self.columns[i] = strings.TrimSpace(name)
+	}
+	return self, nil
+}
+
+func (self *csvReader) Columns() []string {
+	return self.columns
+}
+
+func (self *csvReader) Read() ([]string, error) {
+	self.line++
+	record, err := self.reader.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s line %d: %w", self.path, self.line, err)
+	}
+	if len(record) > len(self.columns) {
+		return nil, fmt.Errorf("%s line %d: %d cells, but the header has %d columns", self.path, self.line, len(record), len(self.columns))
+	}
+	for len(record) < len(self.columns) {
+		record = append(record, "")
+	}
+	return record, nil
+}
+
+func (self *csvReader) Close() error {
+	if self.gzip != nil {
+		self.gzip.Close()
+	}
+	return self.file.Close()
+}
+
+// jsonLinesReader: a file with one JSON object per line, as written by pandas' to_json(orient="records", lines=True).
+// The columns are every key seen in the file, in order of first appearance; a key missing from a row is a missing value, as is null.
+type jsonLinesReader struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	path    string
+	line    int
+	columns []string
+	index   map[string]int
+}
+
+func openJSONLines(path string) (*jsonLinesReader, error) {
+	// One pass to find every column, so each row can be laid out the same way
+	self := &jsonLinesReader{path: path, index: make(map[string]int)}
+	if err := self.open(); err != nil {
+		return nil, err
+	}
+	for {
+		keys, _, err := self.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			self.Close()
+			return nil, err
+		}
+		for _, key := range keys {
+			if _, ok := self.index[key]; !ok {
+				self.index[key] = len(self.columns)
+				self.columns = append(self.columns, key)
+			}
+		}
+	}
+	self.Close()
+	if len(self.columns) == 0 {
+		return nil, fmt.Errorf("reading %s: no JSON objects found", path)
+	}
+	if err := self.open(); err != nil {
+		return nil, err
+	}
+	return self, nil
+}
+
+func (self *jsonLinesReader) open() error {
+	f, err := os.Open(self.path)
+	if err != nil {
+		return err
+	}
+	self.file = f
+	self.scanner = bufio.NewScanner(f)
+	self.scanner.Buffer(nil, maxLineBytes)
+	self.line = 0
+	return nil
+}
+
+// next: decodes the next non-blank line into its keys, in order, and their cells.
+func (self *jsonLinesReader) next() ([]string, map[string]string, error) {
+	for self.scanner.Scan() {
+		self.line++
+		line := self.scanner.Bytes()
+		if self.line == 1 {
+			line = bytes.TrimPrefix(line, []byte(byteOrderMark))
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		keys, cells, err := decodeObject(line)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s line %d: %w", self.path, self.line, err)
+		}
+		return keys, cells, nil
+	}
+	if err := self.scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%s line %d: %w", self.path, self.line+1, err)
+	}
+	return nil, nil, io.EOF
+}
+
+func (self *jsonLinesReader) Columns() []string {
+	return self.columns
+}
+
+func (self *jsonLinesReader) Read() ([]string, error) {
+	_, cells, err := self.next()
+	if err != nil {
+		return nil, err
+	}
+	record := make([]string, len(self.columns))
+	for key, cell := range cells {
+		record[self.index[key]] = cell
+	}
+	return record, nil
+}
+
+func (self *jsonLinesReader) Close() error {
+	return self.file.Close()
+}
+
+// decodeObject: decodes one JSON object of scalar values into its keys, in order, and their values as text cells.
+func decodeObject(line []byte) ([]string, map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, nil, errors.New("line is not a JSON object")
+	}
+	var keys []string
+	cells := make(map[string]string)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := token.(string)
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		if _, seen := cells[key]; !seen {
+			keys = append(keys, key)
+		}
+		switch value := value.(type) {
+		case nil:
+			cells[key] = ""
+		case string:
+			cells[key] = value
+		case json.Number:
+			cells[key] = value.String()
+		case bool:
+			cells[key] = strconv.FormatBool(value)
+		default:
+			return nil, nil, fmt.Errorf("key %q holds a nested value", key)
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, nil, err
+	}
+	return keys, cells, nil
+}
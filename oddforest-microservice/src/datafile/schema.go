@@ -0,0 +1,157 @@
+package datafile
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Column types inferred from a column's non-empty values.
+const (
+	TypeInteger = "integer"
+	TypeFloat   = "float"
+	TypeString  = "string"
+	TypeEmpty   = "empty"
+)
+
+// MaxClasses is the most distinct values a column can have and still get a class balance.
+const MaxClasses = 20
+
+// Schema: the shape of a dataset, profiled from its contents.
+type Schema struct {
+	Rows    int      `json:"rows"`
+	Columns []Column `json:"columns"`
+}
+
+// Column: the profile of one dataset column. Nulls counts empty cells. Min, Max and Mean are only set for numeric columns.
+// ClassBalance counts the rows of each value, for columns with at most MaxClasses distinct values.
+type Column struct {
+	Name         string         `json:"name"`
+	Type         string         `json:"type"`
+	Nulls        int            `json:"nulls"`
+	Min          *float64       `json:"min,omitempty"`
+	Max          *float64       `json:"max,omitempty"`
+	Mean         *float64       `json:"mean,omitempty"`
+	ClassBalance map[string]int `json:"class_balance,omitempty"`
+}
+
+// Names: the column names, in file order.
+func (self *Schema) Names() []string {
+	names := make([]string, len(self.Columns))
+	for i, column := range self.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// Column: looks up a column by name.
+func (self *Schema) Column(name string) (Column, bool) {
+	for _, column := range self.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// Numeric: reports whether every non-empty value of the column is a number.
+func (self Column) Numeric() bool {
+	return self.Type == TypeInteger || self.Type == TypeFloat
+}
+
+// columnProfile: running totals for one column while a dataset is read.
+type columnProfile struct {
+	integers, floats, strings int
+	nulls                     int
+	min, max, sum             float64
+	classes                   map[string]int
+}
+
+func (self *columnProfile) add(cell string) {
+	cell = strings.TrimSpace(cell)
+	if cell == "" {
+		self.nulls++
+		return
+	}
+	if self.classes != nil {
+		self.classes[cell]++
+		if len(self.classes) > MaxClasses {
+			self.classes = nil
+		}
+	}
+	if self.strings != 0 {
+		return
+	}
+	value, err := strconv.ParseFloat(cell, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		self.strings++
+		return
+	}
+	if _, err := strconv.ParseInt(cell, 10, 64); err == nil {
+		self.integers++
+	} else {
+		self.floats++
+	}
+	if self.integers+self.floats == 1 {
+		self.min, self.max = value, value
+	}
+	self.min = math.Min(self.min, value)
+	self.max = math.Max(self.max, value)
+	self.sum += value
+}
+
+func (self *columnProfile) column(name string) Column {
+	column := Column{Name: name, Nulls: self.nulls, ClassBalance: self.classes}
+	switch {
+	case self.strings != 0:
+		column.Type = TypeString
+	case self.floats != 0:
+		column.Type = TypeFloat
+	case self.integers != 0:
+		column.Type = TypeInteger
+	default:
+		column.Type = TypeEmpty
+	}
+	if column.Numeric() {
+		mean := self.sum / float64(self.integers+self.floats)
+		column.Min, column.Max, column.Mean = &self.min, &self.max, &mean
+	}
+	if len(column.ClassBalance) == 0 {
+		column.ClassBalance = nil
+	}
+	return column
+}
+
+// Profile: reads a dataset file of one of the Native formats once and profiles every column.
+func Profile(path string, format string) (*Schema, error) {
+	reader, err := Open(path, format)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	names := reader.Columns()
+	profiles := make([]columnProfile, len(names))
+	for i := range profiles {
+		profiles[i].classes = make(map[string]int)
+	}
+	schema := &Schema{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i, cell := range record {
+			profiles[i].add(cell)
+		}
+		schema.Rows++
+	}
+	schema.Columns = make([]Column, len(names))
+	for i, name := range names {
+		schema.Columns[i] = profiles[i].column(name)
+	}
+	return schema, nil
+}
@@ -0,0 +1,85 @@
+package datafile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	number := func(value float64) *float64 { return &value }
+	// The same three rows in every format: x is missing from the last one
+	rows := Schema{Rows: 3, Columns: []Column{
+		{Name: "x", Type: TypeFloat, Nulls: 1, Min: number(1), Max: number(2.5), Mean: number(1.75), ClassBalance: map[string]int{"1": 1, "2.5": 1}},
+		{Name: "label", Type: TypeString, ClassBalance: map[string]int{"a": 2, "b": 1}},
+	}}
+	csv := "x,label\n1,a\n2.5,b\n,a\n"
+	jsonl := `{"x": 1, "label": "a"}` + "\n" + `{"x": 2.5, "label": "b"}` + "\n" + `{"x": null, "label": "a"}` + "\n"
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		want   Schema
+	}{
+		{"csv", []byte(csv), FormatCSV, rows},
+		{"csv with a byte order mark", []byte("\ufeff" + csv), FormatCSV, rows},
+		{"csv with spaces around names", []byte(" x , label\n1,a\n2.5,b\n,a\n"), FormatCSV, rows},
+		{"csv of unknown format", []byte(csv), "", rows},
+		{"gzip", gzipped(t, csv), FormatCSVGzip, rows},
+		{"gzip with a byte order mark", gzipped(t, "\ufeff"+csv), FormatCSVGzip, rows},
+		{"json lines", []byte(jsonl), FormatJSONLines, rows},
+		{"json lines with a byte order mark", []byte("\ufeff" + jsonl), FormatJSONLines, rows},
+		{"json lines missing a key", []byte(`{"x": 1, "label": "a"}` + "\n\n" + `{"x": 2.5, "label": "b"}` + "\n" + `{"label": "a"}` + "\n"), FormatJSONLines, rows},
+		{
+			"csv with a stray quote and a short row", []byte("x,label\n1,a\"b\n2\n"), FormatCSV,
+			Schema{Rows: 2, Columns: []Column{
+				{Name: "x", Type: TypeInteger, Min: number(1), Max: number(2), Mean: number(1.5), ClassBalance: map[string]int{"1": 1, "2": 1}},
+				{Name: "label", Type: TypeString, Nulls: 1, ClassBalance: map[string]int{`a"b`: 1}},
+			}},
+		},
+		{
+			"json lines of booleans", []byte(`{"flag": true}` + "\n" + `{"flag": false}` + "\n"), FormatJSONLines,
+			Schema{Rows: 2, Columns: []Column{{Name: "flag", Type: TypeString, ClassBalance: map[string]int{"true": 1, "false": 1}}}},
+		},
+		{
+			"header only", []byte("x,label\n"), FormatCSV,
+			Schema{Columns: []Column{{Name: "x", Type: TypeEmpty}, {Name: "label", Type: TypeEmpty}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema, err := Profile(writeFile(t, "dataset", test.data), test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*schema, test.want) {
+				t.Errorf("schema %+v, want %+v", *schema, test.want)
+			}
+		})
+	}
+}
+
+func TestProfileRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		error  string
+	}{
+		{"csv row longer than the header", []byte("x,label\n1,a\n2,b,c\n"), FormatCSV, "line 3: 3 cells, but the header has 2 columns"},
+		{"empty csv", nil, FormatCSV, "reading header"},
+		{"gzip that is not compressed", []byte("x,label\n1,a\n"), FormatCSVGzip, "decompressing"},
+		{"json lines that are not objects", []byte(`{"x": 1}` + "\n[1]\n"), FormatJSONLines, "line 2: line is not a JSON object"},
+		{"json lines with a nested value", []byte(`{"x": {"y": 1}}` + "\n"), FormatJSONLines, `key "x" holds a nested value`},
+		{"parquet", []byte("PAR1"), FormatParquet, "not read by the server"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Profile(writeFile(t, "dataset", test.data), test.format)
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package forest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	datafile "intel.com/oddforest-microservice/datafile"
+)
+
+// ReadDataset: reads the feature columns of a dataset file in one of the datafile.Native formats, plus the label column if label is not empty.
+// Empty cells read as NaN, as they do in pandas.
+func ReadDataset(path string, format string, features []string, label string) ([]map[string]float64, []string, error) {
+	reader, err := datafile.Open(path, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+	columns := make(map[string]int)
+	for i, name := range reader.Columns() {
+		columns[name] = i
+	}
+	feature_columns := make([]int, len(features))
+	for i, feature := range features {
+		column, ok := columns[feature]
+		if !ok {
+			return nil, nil, fmt.Errorf("dataset %s has no column %q", path, feature)
+		}
+		feature_columns[i] = column
+	}
+	label_column := -1
+	if label != "" {
+		column, ok := columns[label]
+		if !ok {
+			return nil, nil, fmt.Errorf("dataset %s has no column %q", path, label)
+		}
+		label_column = column
+	}
+
+	var rows []map[string]float64
+	var labels []string
+	for row_number := 1; ; row_number++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		row := make(map[string]float64, len(features))
+		for i, feature := range features {
+			value, err := ParseValue(record[feature_columns[i]])
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s row %d, column %q: %w", path, row_number, feature, err)
+			}
+			row[feature] = value
+		}
+		rows = append(rows, row)
+		if label_column >= 0 {
+			labels = append(labels, strings.TrimSpace(record[label_column]))
+		}
+	}
+	return rows, labels, nil
+}
+
+// ParseValue: parses a numeric cell. Empty cells are NaN.
+func ParseValue(cell string) (float64, error) {
+	cell = strings.TrimSpace(cell)
+	if cell == "" {
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(cell, 64)
+}
+
+// WritePredictions: writes each row's id (its 0-based position in the dataset) and prediction. Classifiers also get the share of trees that voted for the predicted class.
+func WritePredictions(w io.Writer, regression bool, predictions []Prediction) error {
+	writer := csv.NewWriter(w)
+	header := []string{"row_id", "prediction", "vote_share"}
+	if regression {
+		header = header[:2]
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for i, prediction := range predictions {
+		record := []string{strconv.Itoa(i), prediction.Class}
+		if regression {
+			record[1] = strconv.FormatFloat(prediction.Value, 'g', -1, 64)
+		} else {
+			vote_share := 0.0
+			if len(prediction.TreeVotes) != 0 {
+				vote_share = float64(prediction.Votes[prediction.Class]) / float64(len(prediction.TreeVotes))
+			}
+			record = append(record, strconv.FormatFloat(vote_share, 'g', -1, 64))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,267 @@
+package forest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FormatVersion is the version of the exported forest format written by training, and the only one this package reads.
+const FormatVersion = 2
+
+// Model types of an exported forest. Files without one are classifiers.
+const (
+	Classification = "classification"
+	Regression     = "regression"
+)
+
+// Extension is appended to a model's base name to get the path of its exported forest.
+const Extension = ".forest.json"
+
+// Forest: a trained random forest exported from the Python RandomForestClassifier. See docs/user-guide/model-format.md.
+type Forest struct {
+	FormatVersion   int                  `json:"format_version"`
+	ModelType       string               `json:"model_type,omitempty"`
+	Features        []string             `json:"features"`
+	InferName       string               `json:"infer_name"`
+	Classes         []string             `json:"classes"`
+	Hyperparameters Hyperparameters      `json:"hyperparameters"`
+	ODDBins         map[string][]float64 `json:"odd_bins,omitempty"`
+	Trees           []*Node              `json:"trees"`
+}
+
+// Hyperparameters: the settings the forest was grown with.
+type Hyperparameters struct {
+	NTrees           int     `json:"n_trees"`
+	MaxDepth         int     `json:"max_depth"`
+	MinSamplesSplit  int     `json:"min_samples_split"`
+	FeaturesFraction float64 `json:"x_features_fraction"`
+	ObsFraction      float64 `json:"x_obs_fraction"`
+}
+
+// Node: one node of a tree. Split nodes have both children; a value below Threshold goes left, anything else goes right.
+// Leaves (and any node the Python predictor stops at) carry the class they predict, or for regression forests the value.
+type Node struct {
+	Feature    string         `json:"feature,omitempty"`
+	Threshold  float64        `json:"threshold,omitempty"`
+	Left       *Node          `json:"left,omitempty"`
+	Right      *Node          `json:"right,omitempty"`
+	Prediction string         `json:"prediction,omitempty"`
+	Counts     map[string]int `json:"counts,omitempty"`
+	Value      *float64       `json:"value,omitempty"`
+	Samples    int            `json:"samples,omitempty"`
+}
+
+// Prediction: the forest's prediction for a row. Classifiers give the majority-vote class, along with how many trees voted for each class and what each tree voted;
+// regression forests give the mean of the trees' values, along with each tree's value.
+type Prediction struct {
+	Class      string
+	Votes      map[string]int
+	TreeVotes  []string
+	Value      float64
+	TreeValues []float64
+}
+
+// PathFor: the path of the exported forest that training writes next to a model file.
+func PathFor(model_path string) string {
+	return strings.TrimSuffix(model_path, filepath.Ext(model_path)) + Extension
+}
+
+// Load: reads and validates an exported forest.
+func Load(path string) (*Forest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	forest, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("forest %s: %w", path, err)
+	}
+	return forest, nil
+}
+
+// Decode: parses and validates an exported forest. Unknown fields are rejected, so untrusted uploads cannot smuggle in anything the format does not define.
+func Decode(data []byte) (*Forest, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var forest Forest
+	if err := decoder.Decode(&forest); err != nil {
+		return nil, fmt.Errorf("decoding: %w", err)
+	}
+	if err := forest.Validate(); err != nil {
+		return nil, err
+	}
+	return &forest, nil
+}
+
+// Validate: checks the forest can be evaluated: a known version, at least one tree, and splits only on the forest's features.
+func (self *Forest) Validate() error {
+	if self.FormatVersion != FormatVersion {
+		return fmt.Errorf("unsupported format version %d, expected %d", self.FormatVersion, FormatVersion)
+	}
+	switch self.ModelType {
+	case "", Classification, Regression:
+	default:
+		return fmt.Errorf("unknown model type %q", self.ModelType)
+	}
+	if len(self.Trees) == 0 {
+		return errors.New("forest has no trees")
+	}
+	if len(self.Features) == 0 {
+		return errors.New("forest has no features")
+	}
+	features := make(map[string]bool, len(self.Features))
+	for _, feature := range self.Features {
+		features[feature] = true
+	}
+	for i, tree := range self.Trees {
+		if err := validateNode(tree, features, self.IsRegression()); err != nil {
+			return fmt.Errorf("tree %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateNode(node *Node, features map[string]bool, regression bool) error {
+	if node == nil {
+		return errors.New("missing node")
+	}
+	if (node.Left == nil) != (node.Right == nil) {
+		return errors.New("split node must have both children")
+	}
+	if regression && (node.Value == nil || math.IsNaN(*node.Value) || math.IsInf(*node.Value, 0)) {
+		return errors.New("regression node has no usable value")
+	}
+	if node.Left == nil {
+		return nil
+	}
+	if math.IsNaN(node.Threshold) || math.IsInf(node.Threshold, 0) {
+		return fmt.Errorf("split on %q has no usable threshold", node.Feature)
+	}
+	if !features[node.Feature] {
+		return fmt.Errorf("split on unknown feature %q", node.Feature)
+	}
+	if err := validateNode(node.Left, features, regression); err != nil {
+		return err
+	}
+	return validateNode(node.Right, features, regression)
+}
+
+// IsRegression: reports whether the forest predicts numeric values rather than classes.
+func (self *Forest) IsRegression() bool {
+	return self.ModelType == Regression
+}
+
+// Leaf: walks a single tree for a row of feature values and returns the node evaluation stops at.
+func Leaf(node *Node, row map[string]float64) *Node {
+	for node.Left != nil {
+		if row[node.Feature] < node.Threshold {
+			node = node.Left
+		} else {
+			node = node.Right
+		}
+	}
+	return node
+}
+
+// PredictTree: walks a single tree of a classifier for a row of feature values.
+func PredictTree(node *Node, row map[string]float64) string {
+	return Leaf(node, row).Prediction
+}
+
+// Predict: evaluates every tree. Classifiers return the majority vote, with ties going to the class that received its first vote earliest, as in the Python predictor.
+// Regression forests return the mean of the trees' values.
+func (self *Forest) Predict(row map[string]float64) Prediction {
+	if self.IsRegression() {
+		tree_values := make([]float64, len(self.Trees))
+		total := 0.0
+		for i, tree := range self.Trees {
+			tree_values[i] = *Leaf(tree, row).Value
+			total += tree_values[i]
+		}
+		return Prediction{Value: total / float64(len(self.Trees)), TreeValues: tree_values}
+	}
+	votes := make(map[string]int)
+	tree_votes := make([]string, len(self.Trees))
+	var order []string
+	for i, tree := range self.Trees {
+		class := PredictTree(tree, row)
+		if _, ok := votes[class]; !ok {
+			order = append(order, class)
+		}
+		votes[class]++
+		tree_votes[i] = class
+	}
+	best := ""
+	for _, class := range order {
+		if best == "" || votes[class] > votes[best] {
+			best = class
+		}
+	}
+	return Prediction{Class: best, Votes: votes, TreeVotes: tree_votes}
+}
+
+// VoteFractions: the share of trees that voted for each class.
+func (self Prediction) VoteFractions() map[string]float64 {
+	fractions := make(map[string]float64, len(self.Votes))
+	for class, votes := range self.Votes {
+		fractions[class] = float64(votes) / float64(len(self.TreeVotes))
+	}
+	return fractions
+}
+
+// PredictBatch: predicts every row in order.
+func (self *Forest) PredictBatch(rows []map[string]float64) []Prediction {
+	predictions := make([]Prediction, len(rows))
+	for i, row := range rows {
+		predictions[i] = self.Predict(row)
+	}
+	return predictions
+}
+
+// Cache: loaded forests keyed by path, reloaded when the file on disk changes.
+type Cache struct {
+	mu      sync.Mutex
+	forests map[string]cachedForest
+}
+
+type cachedForest struct {
+	forest   *Forest
+	modified time.Time
+}
+
+// Get: returns the forest at path, loading it on first use or after the file changes.
+func (self *Cache) Get(path string) (*Forest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if cached, ok := self.forests[path]; ok && cached.modified.Equal(info.ModTime()) {
+		return cached.forest, nil
+	}
+	forest, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if self.forests == nil {
+		self.forests = make(map[string]cachedForest)
+	}
+	self.forests[path] = cachedForest{forest: forest, modified: info.ModTime()}
+	return forest, nil
+}
+
+// Evict: drops a forest from the cache, e.g. when its model is deleted.
+func (self *Cache) Evict(path string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	delete(self.forests, path)
+}
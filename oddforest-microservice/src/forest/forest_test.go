@@ -0,0 +1,148 @@
+package forest
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// classifierJSON: three stumps on x. For x below 1.5 the trees vote a, b, c; from 1.5 to 2.5 a, a, c; from 2.5 on b, a, c.
+const classifierJSON = `{
+	"format_version": 2,
+	"features": ["x"],
+	"infer_name": "y",
+	"classes": ["a", "b", "c"],
+	"hyperparameters": {"n_trees": 3, "max_depth": 1, "min_samples_split": 2, "x_features_fraction": 1, "x_obs_fraction": 1},
+	"trees": [
+		{"feature": "x", "threshold": 2.5, "left": {"prediction": "a"}, "right": {"prediction": "b"}},
+		{"feature": "x", "threshold": 1.5, "left": {"prediction": "b"}, "right": {"prediction": "a"}},
+		{"prediction": "c"}
+	]
+}`
+
+// regressorJSON: two stumps on x, worth 1 and 10 below their thresholds and 3 and 20 from them on.
+const regressorJSON = `{
+	"format_version": 2,
+	"model_type": "regression",
+	"features": ["x"],
+	"infer_name": "y",
+	"classes": [],
+	"hyperparameters": {"n_trees": 2, "max_depth": 1, "min_samples_split": 2, "x_features_fraction": 1, "x_obs_fraction": 1},
+	"trees": [
+		{"feature": "x", "threshold": 2.5, "value": 2, "left": {"value": 1}, "right": {"value": 3}},
+		{"feature": "x", "threshold": 1.5, "value": 15, "left": {"value": 10}, "right": {"value": 20}}
+	]
+}`
+
+func decode(t *testing.T, data string) *Forest {
+	t.Helper()
+	rf, err := Decode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rf
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		old, new string
+		error    string
+	}{
+		{"version 1", classifierJSON, `"format_version": 2`, `"format_version": 1`, "unsupported format version 1"},
+		{"newer version", regressorJSON, `"format_version": 2`, `"format_version": 3`, "unsupported format version 3"},
+		{"unknown model type", regressorJSON, `"regression"`, `"ranking"`, `unknown model type "ranking"`},
+		{"unknown feature", classifierJSON, `"feature": "x", "threshold": 1.5`, `"feature": "z", "threshold": 1.5`, `tree 2: split on unknown feature "z"`},
+		{"regression leaf without a value", regressorJSON, `{"value": 20}`, `{}`, "tree 2: regression node has no usable value"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Decode([]byte(strings.Replace(test.data, test.old, test.new, 1)))
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, want one containing %q", err, test.error)
+			}
+		})
+	}
+}
+
+func TestPredictClassifier(t *testing.T) {
+	full := decode(t, classifierJSON)
+	// The first two trees alone, so every row is a tie unless both agree
+	pair := decode(t, classifierJSON)
+	pair.Trees = pair.Trees[:2]
+
+	tests := []struct {
+		name       string
+		rf         *Forest
+		x          float64
+		class      string
+		tree_votes []string
+		fractions  map[string]float64
+	}{
+		// Ties go to the class voted for first, as collections.Counter.most_common does in the Python predictor
+		{"pair tie, first vote wins", pair, 1, "a", []string{"a", "b"}, map[string]float64{"a": 0.5, "b": 0.5}},
+		{"pair tie, other first vote wins", pair, 3, "b", []string{"b", "a"}, map[string]float64{"a": 0.5, "b": 0.5}},
+		{"pair agrees", pair, 2, "a", []string{"a", "a"}, map[string]float64{"a": 1}},
+		{"three-way tie", full, 1, "a", []string{"a", "b", "c"}, map[string]float64{"a": 1.0 / 3, "b": 1.0 / 3, "c": 1.0 / 3}},
+		{"majority", full, 2, "a", []string{"a", "a", "c"}, map[string]float64{"a": 2.0 / 3, "c": 1.0 / 3}},
+		{"threshold goes right", full, 2.5, "b", []string{"b", "a", "c"}, map[string]float64{"a": 1.0 / 3, "b": 1.0 / 3, "c": 1.0 / 3}},
+		// NaN is never below a threshold, so empty cells go right at every split, as in the Python predictor
+		{"NaN goes right", pair, math.NaN(), "b", []string{"b", "a"}, map[string]float64{"a": 0.5, "b": 0.5}},
+		{"NaN goes right, three trees", full, math.NaN(), "b", []string{"b", "a", "c"}, map[string]float64{"a": 1.0 / 3, "b": 1.0 / 3, "c": 1.0 / 3}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prediction := test.rf.Predict(map[string]float64{"x": test.x})
+			if prediction.Class != test.class {
+				t.Errorf("class %q, want %q", prediction.Class, test.class)
+			}
+			if !slices.Equal(prediction.TreeVotes, test.tree_votes) {
+				t.Errorf("tree votes %v, want %v", prediction.TreeVotes, test.tree_votes)
+			}
+			if fractions := prediction.VoteFractions(); !maps.Equal(fractions, test.fractions) {
+				t.Errorf("vote fractions %v, want %v", fractions, test.fractions)
+			}
+		})
+	}
+}
+
+func TestPredictRegressor(t *testing.T) {
+	rf := decode(t, regressorJSON)
+	tests := []struct {
+		name        string
+		x           float64
+		value       float64
+		tree_values []float64
+	}{
+		{"both left", 1, 5.5, []float64{1, 10}},
+		{"split", 2, 10.5, []float64{1, 20}},
+		{"both right", 3, 11.5, []float64{3, 20}},
+		{"NaN goes right", math.NaN(), 11.5, []float64{3, 20}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prediction := rf.Predict(map[string]float64{"x": test.x})
+			if prediction.Value != test.value {
+				t.Errorf("value %v, want %v", prediction.Value, test.value)
+			}
+			if !slices.Equal(prediction.TreeValues, test.tree_values) {
+				t.Errorf("tree values %v, want %v", prediction.TreeValues, test.tree_values)
+			}
+		})
+	}
+}
+
+func TestPredictBatchKeepsOrder(t *testing.T) {
+	rf := decode(t, classifierJSON)
+	rows := []map[string]float64{{"x": 3}, {"x": 1}, {"x": 2}}
+	var classes []string
+	for _, prediction := range rf.PredictBatch(rows) {
+		classes = append(classes, prediction.Class)
+	}
+	if want := []string{"b", "a", "a"}; !slices.Equal(classes, want) {
+		t.Errorf("classes %v, want %v", classes, want)
+	}
+}
@@ -1,9 +1,12 @@
 module intel.com/oddforest-microservice
 
-go 1.21.0
+go 1.23.0
 toolchain go1.23.7
 
-require github.com/gin-gonic/gin v1.9.1
+require (
+	github.com/gin-gonic/gin v1.9.1
+	go.etcd.io/bbolt v1.3.10
+)
 
 require (
 	github.com/BurntSushi/toml v1.3.2
@@ -2,19 +2,34 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"mime/multipart"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/gin-gonic/gin"
+	datafile "intel.com/oddforest-microservice/datafile"
+	forest "intel.com/oddforest-microservice/forest"
+	metrics "intel.com/oddforest-microservice/metrics"
+	runner "intel.com/oddforest-microservice/runner"
+	scheduler "intel.com/oddforest-microservice/scheduler"
 	session "intel.com/oddforest-microservice/session"
+	workspace "intel.com/oddforest-microservice/workspace"
 )
 
 // Debug Flags
@@ -22,6 +37,34 @@ import (
 // Session object
 var current_session session.Session
 
+// Scheduler bounding how many Python subprocesses run at once
+var job_scheduler *scheduler.Scheduler
+
+// Per-task working directories
+var task_workspace *workspace.Workspace
+
+// Exported forests loaded for native inference
+var forest_cache forest.Cache
+
+// Where inference result artifacts are stored
+var results_root string
+
+// Where model files are stored, each under a name of its own so no model's file is ever overwritten by another's
+var models_root string
+
+// Where datasets are stored, and where chunked uploads are assembled until they are finalized
+var datasets_root string
+var uploads_root string
+
+// Whether joblib model uploads are accepted. Loading one runs whatever code it holds, so they are refused unless every client is trusted
+var trusted_model_uploads bool
+
+// Chunked uploads with a request currently writing them
+var uploads_busy = struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}{ids: make(map[string]bool)}
+
 // setupRouter: Sets up the Gin-based http router with our options and our routes.
 func setupRouter() *gin.Engine {
 	router := gin.Default()
@@ -29,21 +72,51 @@ func setupRouter() *gin.Engine {
 	//GET Methods
 	router.GET("/status", getStatus)
 	router.GET("/datasets", getDataset)
+	router.GET("/datasets/:id", getDatasetByID)
+	router.GET("/datasets/:id/schema", getDatasetSchema)
 	router.GET("/models", getModel)
 	router.GET("/models/tree", getModelTree)
+	router.GET("/models/:id", getModelByID)
+	router.GET("/models/:id/download", downloadModel)
+	router.GET("/models/:id/lineage", getModelLineage)
 	router.GET("/results", getResults)
+	router.GET("/results/:id", getResult)
+	router.GET("/results/:id/predictions", getResultPredictions)
+	router.GET("/tasks", getTasks)
+	router.GET("/tasks/:id", getTask)
+	router.GET("/registry", getRegistry)
+	router.GET("/registry/:name", getRegisteredModel)
 	//POST Methods
 	router.POST("/train", startTraining)
 	router.POST("/data/upload", uploadData)
+	router.POST("/data/uploads", startUpload)
+	router.POST("/data/uploads/:id/finalize", finalizeUpload)
 	router.POST("/model/upload", uploadModel)
 	router.POST("/infer", infer)
+	router.POST("/infer/batch", startBatchInference)
+	router.POST("/models/:id/predict", predict)
+	router.POST("/registry/:name/versions", registerModel)
+	router.POST("/registry/:name/promote", promoteModel)
+	router.POST("/registry/:name/rollback", rollbackModel)
+	//PUT Methods
+	router.PUT("/data/uploads/:id", uploadChunk)
+	router.PUT("/registry/:name/aliases/:alias", setModelAlias)
+	//DELETE Methods
+	router.DELETE("/datasets/:id", deleteDataset)
+	router.DELETE("/models/:id", deleteModel)
+	router.DELETE("/results/:id", deleteResult)
+	router.DELETE("/tasks/:id", deleteTask)
+	router.DELETE("/registry/:name/aliases/:alias", deleteModelAlias)
 	return router
 }
 
-// getStatus: Returns a list of: running + finished tasks; uploaded datasets; built models
+// getStatus: Returns a list of: running + finished tasks; uploaded datasets; built models; scheduler queue depth
 func getStatus(c *gin.Context) {
 	// Without ID, return everything. Begin building our return: start by querying the available task list and their status
-	c.JSON(http.StatusOK, current_session)
+	c.JSON(http.StatusOK, struct {
+		session.Catalogue
+		Queue scheduler.Stats
+	}{current_session.Catalogue(), job_scheduler.Stats()})
 	// Query the uploaded datasets
 
 	// Query the available models
@@ -53,42 +126,288 @@ func getStatus(c *gin.Context) {
 	// With ID, only return status of training job
 }
 
-// getDataset: Returns a list of available datasets in the microservice, or if an ID is provided returns information about a specific dataset
+// getDataset: Returns a list of available datasets in the microservice, or with the name query parameter, every version of one dataset
 func getDataset(c *gin.Context) {
-	// With no ID, returns a list of datasets available.
-	c.JSON(http.StatusOK, current_session.Datasets)
-	// If ID present, returns info about that dataset
+	if name := c.Query("name"); name != "" {
+		c.JSON(http.StatusOK, current_session.DatasetVersions(name))
+		return
+	}
+	c.JSON(http.StatusOK, current_session.Datasets())
+}
+
+// getDatasetByID: Returns information about a specific dataset, including the size of its file
+func getDatasetByID(c *gin.Context) {
+	dataset, ok := current_session.GetDataset(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "dataset not found, id: "+c.Param("id"))
+		return
+	}
+	c.JSON(http.StatusOK, session.DatasetDetails{Dataset: dataset, SizeBytes: fileSize(dataset.Path)})
+}
+
+// getDatasetSchema: Returns the profile of a dataset's columns.
+func getDatasetSchema(c *gin.Context) {
+	dataset, ok := current_session.GetDataset(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "dataset not found, id: "+c.Param("id"))
+		return
+	}
+	if !dataset.Ready() {
+		c.JSON(http.StatusConflict, "dataset "+dataset.ID+" is still uploading")
+		return
+	}
+	dataset, err := profileDataset(c.Request.Context(), dataset)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, "dataset could not be read: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, dataset.Schema)
+}
+
+// profileDataset: Returns the dataset with its schema. Datasets catalogued before profiling existed are profiled and saved on first use.
+func profileDataset(ctx context.Context, dataset session.Dataset) (session.Dataset, error) {
+	if dataset.Schema != nil {
+		return dataset, nil
+	}
+	schema, err := profileFile(ctx, dataset.Path, dataset.Format)
+	if err != nil {
+		return dataset, err
+	}
+	return current_session.UpdateDataset(dataset.ID, func(dataset *session.Dataset) error {
+		dataset.ApplySchema(schema)
+		return nil
+	})
+}
+
+// profileFile: Profiles a dataset file. The server profiles the formats it reads itself; Parquet files are profiled by the random forest tool,
+// waiting for the job unless ctx, the context of the request that needs the profile, is done first.
+func profileFile(ctx context.Context, path string, format string) (*datafile.Schema, error) {
+	if datafile.Native(format) {
+		return datafile.Profile(path, format)
+	}
+	task, dir, err := newTask("profile")
+	if err != nil {
+		return nil, err
+	}
+	profileTOMLPath, err := generateProfileTOML(dir, path, format, "profile")
+	if err != nil {
+		rejectTask(task.ID, err)
+		return nil, err
+	}
+	type profileReply struct {
+		result runner.Result
+		err    error
+	}
+	channel_status := make(chan profileReply, 1)
+	err = job_scheduler.Submit(scheduler.Job{ID: task.ID, Priority: scheduler.PriorityHigh, Run: func(ctx context.Context) {
+		log.Println("Profiling dataset...")
+		result, output, err := runTask(ctx, task.ID, profileTOMLPath, dir)
+		if err == nil && result.Schema == nil {
+			err = errors.New("profiling produced no schema")
+			failTask(ctx, task.ID, output, err)
+		} else if err == nil {
+			succeedTask(task.ID, output, nil)
+		}
+		channel_status <- profileReply{result, err}
+	}, OnCancel: func() { channel_status <- profileReply{err: errCancelled} }})
+	if err != nil {
+		rejectTask(task.ID, err)
+		return nil, err
+	}
+	reply, err := awaitJob(ctx, task.ID, channel_status)
+	if err != nil {
+		return nil, err
+	}
+	return reply.result.Schema, reply.err
 }
 
-// getModel: Returns a specific model based on the provided model ID. Without a model ID, it returns a list of available models
+// getModel: Returns a list of available models
 func getModel(c *gin.Context) {
-	// If ID not present in request, query list of available models and return
-	var downloadConfig session.DownloadConfig
-	if err := c.BindTOML(&downloadConfig); err != nil {
-		c.AbortWithError(http.StatusBadRequest, err)
+	c.JSON(http.StatusOK, current_session.Models())
+}
+
+// getModelByID: Returns model details (time of creation, dataset used, size) for a specific model
+func getModelByID(c *gin.Context) {
+	model, ok := resolveModel(c, c.Param("id"))
+	if !ok {
 		return
 	}
-	var model session.Model
-	for _, mod := range current_session.Models {
-		if downloadConfig.ModelID == mod.ID {
-			model = mod
-			log.Printf("Downloading model %s...", model.ID)
-			file, err := os.ReadFile(model.Path)
-			if err != nil {
-				c.AbortWithError(http.StatusBadGateway, err)
-			}
-			c.Data(200, "application/octet-stream", file)
+	details := session.ModelDetails{Model: model, SizeBytes: fileSize(model.Path)}
+	if dataset, ok := current_session.GetDataset(model.TrainedDataset); ok {
+		details.SourceDataset = &dataset
+	}
+	c.JSON(http.StatusOK, details)
+}
+
+// getModelLineage: Returns the dataset version, training request, code version and parent model a model was trained from,
+// and whether that dataset version is still catalogued with the same file
+func getModelLineage(c *gin.Context) {
+	model, ok := resolveModel(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if model.Lineage == nil {
+		c.JSON(http.StatusNotFound, "model "+model.ID+" has no recorded lineage; it was uploaded, or trained before lineage was recorded")
+		return
+	}
+	response := session.LineageResponse{ModelID: model.ID, Lineage: model.Lineage}
+	if dataset, ok := current_session.GetDataset(model.Lineage.DatasetID); ok {
+		response.DatasetAvailable = dataset.SHA256 == model.Lineage.DatasetSHA256
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// downloadModel: Returns the model file itself
+func downloadModel(c *gin.Context) {
+	model, ok := resolveModel(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	log.Printf("Downloading model %s...", model.ID)
+	path := model.Path
+	// ?format=forest serves the portable export instead, which can be uploaded to any server without trusting it
+	if c.Query("format") == "forest" {
+		path = model.ForestPath
+		if path == "" {
+			c.JSON(http.StatusNotFound, "model has no portable export, id: "+model.ID)
 			return
 		}
 	}
-	if model.ID == "" {
-		log.Printf("Model not found: %s\nReturning models list", downloadConfig.ModelID)
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(http.StatusNotFound, "model file not available, id: "+model.ID)
+		return
+	}
+	c.FileAttachment(path, filepath.Base(path))
+}
+
+// resolveModel: Looks up a model by ID or registry reference such as "churn@production", responding 404 if there is none
+func resolveModel(c *gin.Context, ref string) (session.Model, bool) {
+	model, err := current_session.ResolveModel(ref)
+	if err != nil {
+		c.JSON(http.StatusNotFound, err.Error())
+		return session.Model{}, false
+	}
+	return model, true
+}
+
+// getRegistry: Returns every registered model with its versions, stages and aliases
+func getRegistry(c *gin.Context) {
+	c.JSON(http.StatusOK, current_session.Registry())
+}
+
+// getRegisteredModel: Returns a single registered model
+func getRegisteredModel(c *gin.Context) {
+	registered, ok := current_session.GetRegisteredModel(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "registered model not found, name: "+c.Param("name"))
+		return
+	}
+	c.JSON(http.StatusOK, registered)
+}
+
+// registerModel: Adds a model as the next version of a registered model, in staging, creating the registered model if needed
+func registerModel(c *gin.Context) {
+	var register_body session.RegisterModelConfig
+	if err := c.BindTOML(&register_body); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	registered, err := current_session.RegisterModel(c.Param("name"), register_body.ModelID)
+	if err != nil {
+		respondRegistryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, registered)
+}
+
+// promoteModel: Moves a version of a registered model to a stage. Promoting a version to production archives the one it replaces
+func promoteModel(c *gin.Context) {
+	var promote_body session.PromoteConfig
+	if err := c.BindTOML(&promote_body); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	if promote_body.Stage == "" {
+		promote_body.Stage = session.StageProduction
+	}
+	registered, err := current_session.SetStage(c.Param("name"), promote_body.Version, promote_body.Stage)
+	if err != nil {
+		respondRegistryError(c, err)
+		return
+	}
+	log.Printf("Moved %s version %d to %s", registered.Name, promote_body.Version, promote_body.Stage)
+	c.JSON(http.StatusOK, registered)
+}
+
+// rollbackModel: Puts the previous production version of a registered model back in production, archiving the current one
+func rollbackModel(c *gin.Context) {
+	registered, err := current_session.Rollback(c.Param("name"))
+	if err != nil {
+		respondRegistryError(c, err)
+		return
+	}
+	if version, ok := registered.Production(); ok {
+		log.Printf("Rolled %s back to version %d", registered.Name, version.Version)
+	}
+	c.JSON(http.StatusOK, registered)
+}
+
+// setModelAlias: Points an alias of a registered model at one of its versions
+func setModelAlias(c *gin.Context) {
+	var alias_body session.AliasConfig
+	if err := c.BindTOML(&alias_body); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	registered, err := current_session.SetAlias(c.Param("name"), c.Param("alias"), alias_body.Version)
+	if err != nil {
+		respondRegistryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, registered)
+}
+
+// deleteModelAlias: Removes an alias of a registered model
+func deleteModelAlias(c *gin.Context) {
+	registered, err := current_session.RemoveAlias(c.Param("name"), c.Param("alias"))
+	if err != nil {
+		respondRegistryError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, current_session.Models)
-	// If ID present, check if model exists. If yes, return model details (time of creation, dataset used, size)
+	c.JSON(http.StatusOK, registered)
+}
+
+// respondRegistryError: Responds 404 for unknown registered models, versions, aliases and models, and 409 for changes the registry refuses
+func respondRegistryError(c *gin.Context, err error) {
+	var not_found *session.NotFoundError
+	if errors.As(err, &not_found) {
+		c.JSON(http.StatusNotFound, err.Error())
+		return
+	}
+	c.JSON(http.StatusConflict, err.Error())
+}
 
-	// If ID present and not exists, return error to user (http.StatusNotFound))
+// fileSHA256: Returns the hex SHA-256 of a file's contents, reading it as a stream
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
 
+// fileSize: Returns the size of a file on disk, or 0 if it cannot be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
 }
 
 func getModelTree(c *gin.Context) {
@@ -98,23 +417,51 @@ func getModelTree(c *gin.Context) {
 		return
 	}
 	var model session.Model
-	for _, mod := range current_session.Models {
+	for _, mod := range current_session.Models() {
 		if downloadConfig.ModelID == mod.ID {
 			model = mod
-			treesTOMLPath := generateTreesTOML(model.Path, "show_trees")
-			channel_status := make(chan []byte)
-			go func() {
-				cmd := exec.Command("python", "../..//random_forest/main.py", treesTOMLPath)
+			if model.Path == model.ForestPath {
+				c.JSON(http.StatusUnprocessableEntity, "model "+model.ID+" was uploaded as a portable export; its trees are only viewable from the joblib model")
+				return
+			}
+			task, dir, err := newTask("show_trees")
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, err.Error())
+				return
+			}
+			treesTOMLPath, err := generateTreesTOML(dir, model.Path, "show_trees")
+			if err != nil {
+				rejectTask(task.ID, err)
+				c.JSON(http.StatusInternalServerError, err.Error())
+				return
+			}
+			type treesReply struct {
+				result runner.Result
+				err    error
+			}
+			channel_status := make(chan treesReply, 1)
+			err = job_scheduler.Submit(scheduler.Job{ID: task.ID, Priority: scheduler.PriorityHigh, Run: func(ctx context.Context) {
 				log.Println("Showing model tree...")
-				out, err := cmd.CombinedOutput()
-				if err != nil {
-					log.Println(err)
+				result, output, err := runTask(ctx, task.ID, treesTOMLPath, dir)
+				if err == nil {
+					succeedTask(task.ID, output, nil)
 				}
-				current_session.Tasks[len(current_session.Tasks)-1].Status = "Complete"
-				channel_status <- out
-			}()
-			response := strings.Split(strings.TrimSuffix(string(<-channel_status), "\n"), "\n")
-			c.JSON(http.StatusOK, response)
+				channel_status <- treesReply{result, err}
+			}, OnCancel: func() { channel_status <- treesReply{err: errCancelled} }})
+			if err != nil {
+				rejectTask(task.ID, err)
+				c.JSON(http.StatusTooManyRequests, err.Error())
+				return
+			}
+			reply, err := awaitJob(c.Request.Context(), task.ID, channel_status)
+			if err != nil {
+				return
+			}
+			if reply.err != nil {
+				respondRunnerError(c, reply.err)
+				return
+			}
+			c.JSON(http.StatusOK, reply.result.Trees)
 			return
 
 		}
@@ -122,22 +469,27 @@ func getModelTree(c *gin.Context) {
 	if model.ID == "" {
 		log.Printf("Model not found: %s\nReturning models list", downloadConfig.ModelID)
 	}
-	c.JSON(http.StatusOK, current_session.Models)
+	c.JSON(http.StatusOK, current_session.Models())
 }
 
-// getResults: Returns a specfiic inference job's results based on the provided run ID. Without a run ID, it returns a list of available result runs
+// getResults: Returns a list of available result runs, optionally only those for the model_id and/or dataset_id query parameters
 func getResults(c *gin.Context) {
-	// If job ID not present, return list of available results
-	c.JSON(http.StatusOK, current_session.Results)
-	// If ID is present, check if run exists, and then return the run results (model used, dataset trained with, dataset ran, results from run)
+	c.JSON(http.StatusOK, current_session.FindResults(c.Query("model_id"), c.Query("dataset_id")))
+}
 
-	// If ID present and not exists, return error ot user (http.StatusNotFound)
+// getResult: Returns the results of a specific inference run (model used, dataset ran, results from run)
+func getResult(c *gin.Context) {
+	result, ok := current_session.GetResult(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "result not found, id: "+c.Param("id"))
+		return
+	}
+	c.JSON(http.StatusOK, result)
 }
 
-// startTraining: Based on an input TOML file, builds a new model and assigns it an ID.
+// startTraining: Based on an input TOML file, queues a new model build and returns the ID of the task tracking it.
 func startTraining(c *gin.Context) {
 	// If TOML provided, check for valid dataset ID. If everything's ready, start a training task as a trackable async goroutine. Add said goroutine to the list of tasks
-	// While job runs in background, return list of features from dataset and number of datapoints being trained.
 	log.Println("Building new model...")
 	// Check TOML:
 	var training_body session.TrainingConfig
@@ -145,296 +497,1305 @@ func startTraining(c *gin.Context) {
 		c.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
-	// Ready? Get model information ready, set new ID for this job, and add it to the list. Set status to "Getting Ready"
-	// New Model
-	var existing_model_ids []int
-	max_id := 0
-	for _, model := range current_session.Models {
-		existing_model_ids = append(existing_model_ids, model.ID_num)
+	log.Println(training_body)
+	// Check the request against the dataset's schema now, rather than have the training tool fail on it later
+	dataset, problems := validateTrainingRequest(c.Request.Context(), training_body)
+	if len(problems) != 0 {
+		c.JSON(http.StatusUnprocessableEntity, session.ValidationResponse{Error: "invalid training request", Problems: problems})
+		return
+	}
+	// New Task, with its own working directory for config, logs and outputs
+	new_task, dir, err := newTask("train")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
 	}
-	if len(existing_model_ids) != 0 {
-		max_id = slices.Max(existing_model_ids)
+
+	// Get our train config TOML ready - get the dataset path, get the features, get the data. The model file is named after the task;
+	// the name is only the model's label
+	model_path := session.TrainedModelPath(models_root, new_task.ID)
+	trainingtomlpath, err := generateTrainingTOML(dir, dataset.Path, dataset.Format, model_path, forest.PathFor(model_path), training_body.InferName, "train", training_body.Features, training_body.MaxDepth, training_body.NTrees, training_body.SampleSplit, training_body.FeaturesFraction, training_body.DataSplit, training_body.ShowUnoptimzied, training_body.Average, training_body.ModelType)
+	if err != nil {
+		rejectTask(new_task.ID, err)
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
 	}
-	log.Println(training_body)
-	var new_model session.Model
-	new_model.ID_num = max_id + 1
-	new_model.ID = "m" + fmt.Sprint(new_model.ID_num)
-	new_model.Name = training_body.Name
-	new_model.TrainedDataset = training_body.DatasetID
-	new_model.Path = "/storage/models/" + new_model.Name + ".model"
-	new_model.Features = training_body.Features
-	new_model.InferName = training_body.InferName
-	// New Task
-	var existing_task_ids []int
-	for _, task := range current_session.Tasks {
-		existing_task_ids = append(existing_task_ids, task.ID_num)
-	}
-	if len(existing_task_ids) != 0 {
-		max_id = slices.Max(existing_task_ids)
-	}
-	var new_task session.Task
-	new_task.ModelID = new_model.ID
-	new_task.ID_num = max_id + 1
-	new_task.ID = "t" + fmt.Sprint(new_task.ID_num)
-	new_task.Status = "Running"
-	current_session.Tasks = append(current_session.Tasks, new_task)
-
-	// Get our train config TOML ready - get the dataset path, get the features, get the data, get the name to set the path
-	// Prep the environment and send the config to the training tool. Start a goroutine to handle running and set status to "running"
-	dataset_path := ""
-	for _, dataset := range current_session.Datasets {
-		if dataset.ID == new_model.TrainedDataset {
-			dataset_path = dataset.Path
-			break
-		}
-		response := "dataset not found, id: " + new_model.TrainedDataset
-		c.JSON(http.StatusBadRequest, response)
-	}
-
-	// Read in our CSV file
-	features := training_body.Features
-	trainingtomlpath := generateTrainingTOML(dataset_path, new_model.Path, training_body.InferName, "train", features, training_body.MaxDepth, training_body.NTrees, training_body.SampleSplit, training_body.FeaturesFraction, training_body.DataSplit, training_body.ShowUnoptimzied)
-	channel_status := make(chan []byte)
-	go func() {
-		cmd := exec.Command("python", "../..//random_forest/main.py", trainingtomlpath)
-		log.Println("Starting training...")
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			log.Println(err)
-		}
-		current_session.Tasks[len(current_session.Tasks)-1].Status = "Complete"
-		channel_status <- out
-	}()
-	response := strings.Split(strings.TrimSuffix(string(<-channel_status), "\n"), "\n")
-	log.Println(response)
-	new_model.UnoptValAccuracy, _ = strconv.ParseFloat(response[len(response)-8], 64)
-	new_model.UnoptValRecall, _ = strconv.ParseFloat(response[len(response)-7], 64)
-	new_model.UnoptTestAccuracy, _ = strconv.ParseFloat(response[len(response)-6], 64)
-	new_model.UnoptTestRecall, _ = strconv.ParseFloat(response[len(response)-5], 64)
-	new_model.ValAccuracy, _ = strconv.ParseFloat(response[len(response)-4], 64)
-	new_model.ValRecall, _ = strconv.ParseFloat(response[len(response)-3], 64)
-	new_model.TestAccuracy, _ = strconv.ParseFloat(response[len(response)-2], 64)
-	new_model.TestRecall, _ = strconv.ParseFloat(response[len(response)-1], 64)
-
-	current_session.Models = append(current_session.Models, new_model)
-
-	// Return a good status to the user.
-	c.JSON(http.StatusOK, new_model)
+
+	// Prep the environment and queue the training tool on the scheduler. The client polls /tasks/:id for progress.
+	// Training never outranks the requests clients are blocked on, whatever priority it asks for
+	priority := min(training_body.Priority, scheduler.PriorityHigh-1)
+	err = job_scheduler.Submit(scheduler.Job{ID: new_task.ID, Priority: priority, Timeout: time.Duration(training_body.TimeoutSeconds) * time.Second, Run: func(ctx context.Context) {
+		runTraining(ctx, new_task.ID, training_body, dataset, model_path, trainingtomlpath, dir)
+	}})
+	if err != nil {
+		rejectTask(new_task.ID, err)
+		c.JSON(http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusAccepted, session.TrainingResponse{Response: "training queued", TaskID: new_task.ID})
 }
 
-// uploadData: Uploads a provided dataset (.csv) to the microservice datastore and assigns it an ID.
-func uploadData(c *gin.Context) {
-	// Check if dataset is a .csv. If valid (trust the user to add a valid dataset), extract the feature list and then assign it an ID for future use.
+// trainingMetricsComplete: Reports whether a training run returned the validation and test metrics for the kind of model it trained.
+func trainingMetricsComplete(result runner.Result, model_type string) bool {
+	if model_type == forest.Regression {
+		return result.RegressionTraining != nil && result.RegressionTraining.Validation != nil && result.RegressionTraining.Test != nil
+	}
+	return result.Training != nil && result.Training.Validation != nil && result.Training.Test != nil
+}
 
-	file, err := c.FormFile("file")
+// validateTrainingRequest: Checks a training request against its dataset's schema, returning the dataset and every problem found.
+// Each feature must be a numeric column of the dataset. The label column must exist, not be a feature, and have a label on every row;
+// classifiers need at least two classes, and binary averaging exactly two, while regression forests need a numeric target with at least two distinct values.
+func validateTrainingRequest(ctx context.Context, training_body session.TrainingConfig) (session.Dataset, []session.ValidationProblem) {
+	var problems []session.ValidationProblem
+	regression := training_body.ModelType == forest.Regression
+	if training_body.Name == "" {
+		problems = append(problems, session.ValidationProblem{Field: "name", Message: "a model name is required"})
+	}
+	if len(training_body.Features) == 0 {
+		problems = append(problems, session.ValidationProblem{Field: "features", Message: "at least one feature is required"})
+	}
+	if training_body.InferName == "" {
+		problems = append(problems, session.ValidationProblem{Field: "infer_name", Message: "a label column is required"})
+	}
+	if training_body.ModelType != "" && training_body.ModelType != forest.Classification && !regression {
+		problems = append(problems, session.ValidationProblem{Field: "model_type", Message: "must be " + forest.Classification + " or " + forest.Regression})
+	}
+	if training_body.DataSplit <= 0 || training_body.DataSplit >= 1 {
+		problems = append(problems, session.ValidationProblem{Field: "data_split", Message: "must be between 0 and 1, the share of rows the forest is grown on"})
+	}
+	if regression && training_body.Average != "" {
+		problems = append(problems, session.ValidationProblem{Field: "average", Message: "only applies to classification models"})
+	}
+	if training_body.Average != "" && !slices.Contains(metrics.Averages, training_body.Average) {
+		problems = append(problems, session.ValidationProblem{Field: "average", Message: "must be one of " + strings.Join(metrics.Averages, ", ")})
+	}
+	if training_body.InferName != "" && slices.Contains(training_body.Features, training_body.InferName) {
+		problems = append(problems, session.ValidationProblem{Field: training_body.InferName, Message: "label column is also listed as a feature"})
+	}
+	dataset, ok := current_session.GetDataset(training_body.DatasetID)
+	if !ok {
+		return dataset, append(problems, session.ValidationProblem{Field: "dataset_id", Message: "dataset not found, id: " + training_body.DatasetID})
+	}
+	if !dataset.Ready() {
+		return dataset, append(problems, session.ValidationProblem{Field: "dataset_id", Message: "dataset " + dataset.ID + " is still uploading"})
+	}
+	dataset, err := profileDataset(ctx, dataset)
 	if err != nil {
-		c.String(http.StatusBadRequest, "Form error %s", err.Error())
+		return dataset, append(problems, session.ValidationProblem{Field: "dataset_id", Message: "dataset could not be read: " + err.Error()})
 	}
-	filename := filepath.Base(file.Filename)
-	fmt.Println(filename)
-	path := "/storage/datasets/"
-	if err := c.SaveUploadedFile(file, "/storage/datasets/"+filename); err != nil {
-		if err := c.SaveUploadedFile(file, "./"+filename); err != nil {
-			c.String(http.StatusBadRequest, "Error uploading file: %s", err.Error())
-			return
+
+	for _, feature := range training_body.Features {
+		column, ok := dataset.Schema.Column(feature)
+		switch {
+		case !ok:
+			problems = append(problems, session.ValidationProblem{Field: feature, Message: "feature column not found in dataset " + dataset.ID})
+		case !column.Numeric():
+			problems = append(problems, session.ValidationProblem{Field: feature, Message: "feature column is " + column.Type + ", not numeric"})
 		}
-		c.String(http.StatusOK, "storage mount not available, saving locally")
-		path = "./"
 	}
-	var existing_dataset_ids []int
-	max_id := 0
-	for _, model := range current_session.Models {
-		existing_dataset_ids = append(existing_dataset_ids, model.ID_num)
+	if training_body.InferName == "" {
+		return dataset, problems
 	}
-	if len(existing_dataset_ids) != 0 {
-		max_id = slices.Max(existing_dataset_ids)
+	label, ok := dataset.Schema.Column(training_body.InferName)
+	if !ok {
+		return dataset, append(problems, session.ValidationProblem{Field: training_body.InferName, Message: "label column not found in dataset " + dataset.ID})
 	}
-	fmt.Println("assinging dataset id: " + fmt.Sprint(max_id+1))
-	// Give our session the new dataset
-	var new_dataset session.Dataset
-	new_dataset.ID_num = max_id + 1
-	new_dataset.ID = "d" + fmt.Sprint(new_dataset.ID_num)
-	new_dataset.Name = strings.TrimSuffix(filename, ".csv")
-	new_dataset.Path = path + filename
-	current_session.Datasets = append(current_session.Datasets, new_dataset)
-	//Return good status
-	c.JSON(http.StatusOK, new_dataset)
-}
-
-// uploadModel: Uploads a previously downloaded model to the microservice datastore, verifies it, and assigns it an ID
-func uploadModel(c *gin.Context) {
-	log.Println("Uploading Model...")
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.String(http.StatusBadRequest, "Form error %s", err.Error())
+	if label.Nulls != 0 {
+		problems = append(problems, session.ValidationProblem{Field: training_body.InferName, Message: fmt.Sprintf("%d of %d rows have no label", label.Nulls, dataset.Schema.Rows)})
 	}
-	filename := filepath.Base(file.Filename)
-	fmt.Println(filename)
-	if err := c.SaveUploadedFile(file, "/storage/models/"+filename); err != nil {
-		if err := c.SaveUploadedFile(file, "./"+filename); err != nil {
-			c.String(http.StatusBadRequest, "Error uploading file: %s", err.Error())
-			return
+	if regression {
+		switch {
+		case !label.Numeric():
+			problems = append(problems, session.ValidationProblem{Field: training_body.InferName, Message: "regression target is " + label.Type + ", not numeric"})
+		case *label.Min == *label.Max:
+			problems = append(problems, session.ValidationProblem{Field: training_body.InferName, Message: "regression target needs at least 2 distinct values, found 1"})
 		}
-		c.String(http.StatusOK, "storage mount not available, saving locally")
+		return dataset, problems
 	}
-	// New Model
-	var existing_model_ids []int
-	max_id := 0
-	for _, model := range current_session.Models {
-		existing_model_ids = append(existing_model_ids, model.ID_num)
+	// Columns with more than MaxClasses distinct values have no class balance, and certainly more than two classes
+	classes, found := len(label.ClassBalance), strconv.Itoa(len(label.ClassBalance))
+	if label.ClassBalance == nil && label.Type != datafile.TypeEmpty {
+		classes, found = datafile.MaxClasses+1, fmt.Sprintf("more than %d", datafile.MaxClasses)
 	}
-	if len(existing_model_ids) != 0 {
-		max_id = slices.Max(existing_model_ids)
+	switch {
+	case classes < 2:
+		problems = append(problems, session.ValidationProblem{Field: training_body.InferName, Message: "label column needs at least 2 classes, found " + found})
+	case training_body.Average == metrics.AverageBinary && classes > 2:
+		problems = append(problems, session.ValidationProblem{Field: "average", Message: "binary averaging needs 2 classes, found " + found})
 	}
-	var new_model session.Model
-	new_model.ID_num = max_id + 1
-	new_model.ID = "m" + fmt.Sprint(new_model.ID_num)
-	new_model.Name = filename
-	new_model.TrainedDataset = "unknown"
-	new_model.Path = "/storage/models/" + new_model.Name + ".model"
-	new_model.Features = []string{"features"}
-	new_model.InferName = "unknown"
-	current_session.Models = append(current_session.Models, new_model)
-	// Return good status coode
-	c.JSON(http.StatusOK, new_model)
+	return dataset, problems
 }
 
-// infer: infers on a defined dataset with a defined model, and returns the results.
-func infer(c *gin.Context) {
-	// take TOML with info on model and dataset. With only model, use the same dataset. Return results from inference
-	// Load up our chosen model (make sure it exists, get its full path on disk),
-	log.Println("Inferring with model...")
-	var infer_body session.InferConfig
-	if err := c.BindTOML(&infer_body); err != nil {
-		c.AbortWithError(http.StatusBadRequest, err)
+// runTraining: runs the Python training script for a task, records its output, and registers the model once it succeeds.
+func runTraining(ctx context.Context, task_id string, training_body session.TrainingConfig, dataset session.Dataset, model_path string, trainingtomlpath string, dir string) {
+	log.Println("Starting training...")
+	// The file could have changed on disk since it was stored, or while the task was queued
+	dataset, err := verifyDataset(dataset)
+	if err != nil {
+		log.Println(err)
+		failTask(ctx, task_id, runner.Output{}, err)
 		return
 	}
-	//load up our dataset (if available - otherwise use the dataset used to train it. If that's not available in the model information, return an error)
-	dataset_path := ""
-	for _, dataset := range current_session.Datasets {
-		if dataset.ID == infer_body.DatasetID {
-			dataset_path = dataset.Path
-			break
-		}
-		response := "dataset not found, id: " + infer_body.DatasetID
-		c.JSON(http.StatusBadRequest, response)
+	code_version, err := runner.CodeVersion()
+	if err != nil {
+		log.Println(err)
+	}
+	result, output, err := runTask(ctx, task_id, trainingtomlpath, dir)
+	if err == nil && !trainingMetricsComplete(result, training_body.ModelType) {
+		err = errors.New("training produced no metrics")
+		failTask(ctx, task_id, output, err)
+	}
+	if err != nil {
+		// Killed or crashed part way through: don't leave a partial model behind
+		removePartialModel(model_path)
+		return
 	}
 
-	model_path := ""
-	model_infer_name := ""
-	var model_features []string
-	for _, model := range current_session.Models {
-		if model.ID == infer_body.ModelID {
-			model_path = model.Path
-			model_infer_name = model.InferName
-			model_features = model.Features
-			break
+	// New Model
+	var new_model session.Model
+	new_model.Name = training_body.Name
+	new_model.TrainedDataset = training_body.DatasetID
+	new_model.Path = model_path
+	new_model.Features = training_body.Features
+	new_model.InferName = training_body.InferName
+	new_model.CreatedAt = time.Now().UTC()
+	new_model.Lineage = &session.Lineage{DatasetID: dataset.ID, DatasetName: dataset.Name, DatasetVersion: dataset.Version, DatasetSHA256: dataset.SHA256, Config: training_body, CodeVersion: code_version}
+	if parent, ok := current_session.LatestModelNamed(training_body.Name); ok {
+		new_model.Lineage.ParentModelID = parent.ID
+	}
+	if rf, err := forest_cache.Get(forest.PathFor(model_path)); err == nil {
+		new_model.ApplyForest(rf, forest.PathFor(model_path))
+	}
+	if training_body.ModelType == forest.Regression {
+		new_model.ModelType = forest.Regression
+		new_model.RegressionMetrics = result.RegressionTraining
+	} else {
+		new_model.ModelType = forest.Classification
+		new_model.Average = training_body.Average
+		new_model.Metrics = result.Training
+		if len(new_model.Classes) == 0 {
+			new_model.Classes = result.Training.Validation.Classes
 		}
-		response := "dataset not found, id: " + infer_body.ModelID
-		c.JSON(http.StatusBadRequest, response)
 	}
-	infertomlpath := generateInferenceTOML(dataset_path, model_path, model_infer_name, "infer", model_features)
 
-	// Infer, store the results
-	channel_status := make(chan []byte)
-	go func() {
-		cmd := exec.Command("python", "/app/random_forest/main.py", infertomlpath)
-		log.Println("Starting inference...")
-		output, err := cmd.Output()
-		if err != nil {
-			log.Println(err)
-		}
-		current_session.Tasks[len(current_session.Tasks)-1].Status = "Complete"
-		channel_status <- output
-	}()
-	response := strings.Split(strings.TrimSuffix(string(<-channel_status), "\n"), "\n")
-	log.Println(response)
-	trainedprecision, _ := strconv.ParseFloat(response[0], 64)
-	trainedrecall, _ := strconv.ParseFloat(response[1], 64)
-	// Return a good status to the user.
-	c.JSON(http.StatusOK, session.InferenceResponse{TrainedPrecision: trainedprecision, TrainedRecall: trainedrecall})
-	// Return the inference results and a good status code
+	new_model = current_session.AddModel(new_model)
+	succeedTask(task_id, output, func(task *session.Task) {
+		task.ModelID = new_model.ID
+	})
 }
 
-func generateTrainingTOML(filepath string, modelpath string, infername string, tasktype string, config []string, depth int, trees int, samplesplit int, fraction float64, datasplit float64, showunoptmizied bool) string {
-	path := "/storage/train.toml"
-	trainingToml := session.RandomForestTrainingConfig{TaskType: tasktype, FilePath: filepath, Features: config, InferenceName: infername, ModelPath: modelpath, NTrees: trees, SampleSplit: samplesplit, MaxDepth: depth, FeaturesFraction: fraction, DataSplit: datasplit, ShowUnoptimzied: showunoptmizied}
-	buf := new(bytes.Buffer)
-	err := toml.NewEncoder(buf).Encode(trainingToml)
+// newTask: Registers a queued task of the given type and creates its working directory.
+func newTask(task_type string) (session.Task, string, error) {
+	task := current_session.AddTask(task_type)
+	dir, err := task_workspace.Create(task.ID)
 	if err != nil {
-		log.Fatal(err)
+		rejectTask(task.ID, err)
+		return task, "", err
 	}
-	// Write to file
-	f, err := os.Create(path)
-	defer f.Close()
-	if err != nil {
-		path = "./train.toml"
-		f, err = os.Create(path)
-		log.Println("storage volume not available, writing to local directory")
-		if err != nil {
-			log.Fatal(err)
-		}
+	task, err = current_session.UpdateTask(task.ID, func(task *session.Task) error {
+		task.WorkDir = dir
+		return nil
+	})
+	return task, dir, err
+}
 
-	}
-	_, err = f.Write(buf.Bytes())
-	if err != nil {
-		log.Fatal(err)
-	}
-	return path
+// rejectTask: Records a task that could not be handed to the scheduler.
+func rejectTask(task_id string, err error) {
+	current_session.UpdateTask(task_id, func(task *session.Task) error {
+		task.Error = err.Error()
+		return task.Transition(session.TaskFailed)
+	})
 }
 
-func generateInferenceTOML(filepath string, modelpath string, infername string, tasktype string, config []string) string {
-	path := "/storage/infer.toml"
-	trainingToml := session.RandomForestTrainingConfig{TaskType: tasktype, FilePath: filepath, Features: config, InferenceName: infername, ModelPath: modelpath}
-	buf := new(bytes.Buffer)
-	err := toml.NewEncoder(buf).Encode(trainingToml)
+// runTask: Runs main.py for a task on a scheduler worker. Failures are recorded on the task; successful runs are left for the caller to finish with succeedTask.
+func runTask(ctx context.Context, task_id string, config_path string, dir string) (runner.Result, runner.Output, error) {
+	current_session.UpdateTask(task_id, func(task *session.Task) error { return task.Transition(session.TaskRunning) })
+	result, output, err := runner.Run(ctx, config_path, dir)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
+		failTask(ctx, task_id, output, err)
 	}
-	// Write to file
-	f, err := os.Create(path)
-	defer f.Close()
-	if err != nil {
-		path = "./infer.toml"
-		f, err = os.Create(path)
-		log.Println("storage volume not available, writing to local directory")
-		if err != nil {
-			log.Fatal(err)
-		}
+	return result, output, err
+}
 
+// failTask: Finishes a task as failed, cancelled or timed out, depending on why it stopped.
+func failTask(ctx context.Context, task_id string, output runner.Output, err error) {
+	status := session.TaskFailed
+	switch ctx.Err() {
+	case context.Canceled:
+		status = session.TaskCancelled
+	case context.DeadlineExceeded:
+		status = session.TaskTimedOut
+		err = errors.New("task exceeded its timeout")
 	}
-	_, err = f.Write(buf.Bytes())
-	if err != nil {
-		log.Fatal(err)
-	}
-	return path
+	current_session.UpdateTask(task_id, func(task *session.Task) error {
+		task.Stdout = output.Stdout
+		task.Stderr = output.Stderr
+		task.Error = err.Error()
+		return task.Transition(status)
+	})
 }
 
-func generateTreesTOML(modelpath string, tasktype string) string {
-	path := "/storage/trees.toml"
-	treesTOML := session.RandomForestTrainingConfig{TaskType: tasktype, ModelPath: modelpath}
-	buf := new(bytes.Buffer)
-	err := toml.NewEncoder(buf).Encode(treesTOML)
+// succeedTask: Finishes a task as succeeded, applying any final changes to its record.
+func succeedTask(task_id string, output runner.Output, update func(task *session.Task)) {
+	current_session.UpdateTask(task_id, func(task *session.Task) error {
+		task.Stdout = output.Stdout
+		task.Stderr = output.Stderr
+		if update != nil {
+			update(task)
+		}
+		return task.Transition(session.TaskSucceeded)
+	})
+}
+
+// collectTaskDirs: Periodically removes the working directories of tasks that finished longer ago than the retention period,
+// and chunked uploads that have not moved on for longer than upload_expiry.
+func collectTaskDirs(retention time.Duration, upload_expiry time.Duration, interval time.Duration) {
+	for {
+		expireUploads(upload_expiry)
+		removed := task_workspace.Collect(retention, func(task_id string) (time.Time, bool, bool) {
+			task, ok := current_session.GetTask(task_id)
+			if !ok {
+				return time.Time{}, false, false
+			}
+			if !task.Finished() || task.FinishedAt == nil {
+				return time.Time{}, true, true
+			}
+			return *task.FinishedAt, false, true
+		})
+		for _, task_id := range removed {
+			current_session.UpdateTask(task_id, func(task *session.Task) error {
+				task.WorkDir = ""
+				return nil
+			})
+		}
+		time.Sleep(interval)
+	}
+}
+
+// expireUploads: Removes chunked uploads that have received nothing, nor been finalized, for longer than expiry, along with their part files.
+func expireUploads(expiry time.Duration) {
+	cutoff := time.Now().UTC().Add(-expiry)
+	stale := func(dataset session.Dataset) bool {
+		return !dataset.Ready() && dataset.Upload != nil && dataset.Upload.UpdatedAt.Before(cutoff)
+	}
+	for _, dataset := range current_session.Datasets() {
+		// An upload being written to is not stale, whatever it was when the catalogue was read
+		if !stale(dataset) || !claimUpload(dataset.ID) {
+			continue
+		}
+		if dataset, ok := current_session.GetDataset(dataset.ID); ok && stale(dataset) {
+			if err := removeFile(dataset.Upload.PartPath); err != nil {
+				log.Println(err)
+			} else {
+				log.Printf("upload %s of %s expired after %d of its bytes", dataset.ID, dataset.Upload.Filename, dataset.Upload.ReceivedBytes)
+				current_session.RemoveDataset(dataset.ID)
+			}
+		}
+		releaseUpload(dataset.ID)
+	}
+}
+
+// predict: Predicts the class of one or many records, given as JSON objects keyed by the model's features
+func predict(c *gin.Context) {
+	model, ok := resolveModel(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if model.ForestPath == "" {
+		c.JSON(http.StatusUnprocessableEntity, "model "+model.ID+" has no portable export to predict with")
+		return
+	}
+	rf, err := forest_cache.Get(model.ForestPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPredictBody))
 	if err != nil {
-		log.Fatal(err)
+		c.JSON(http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	records, err := decodeRecords(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, problems := recordsToRows(records, rf.Features)
+	if len(problems) != 0 {
+		c.JSON(http.StatusUnprocessableEntity, session.ValidationResponse{Error: "invalid records", Problems: problems})
+		return
+	}
+	response := session.PredictResponse{ModelID: model.ID}
+	for i, prediction := range rf.PredictBatch(rows) {
+		row := session.RowPrediction{Row: i + 1, Class: prediction.Class, Votes: prediction.Votes, TreeVotes: prediction.TreeVotes}
+		if rf.IsRegression() {
+			row.Value = &prediction.Value
+			row.TreeValues = prediction.TreeValues
+		}
+		response.Predictions = append(response.Predictions, row)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// maxPredictBody: the largest predict request body accepted
+const maxPredictBody = 32 << 20
+
+// decodeRecords: Decodes a predict request body holding either a single JSON object or an array of them
+func decodeRecords(body []byte) ([]map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) != 0 && trimmed[0] == '[' {
+		var records []map[string]any
+		if err := decoder.Decode(&records); err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, errors.New("no records to predict")
+		}
+		return records, nil
+	}
+	var record map[string]any
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	return []map[string]any{record}, nil
+}
+
+// recordsToRows: Converts decoded records into feature rows, reporting every missing, extra or non-numeric column. null values read as missing data (NaN).
+func recordsToRows(records []map[string]any, features []string) ([]map[string]float64, []session.ValidationProblem) {
+	var problems []session.ValidationProblem
+	rows := make([]map[string]float64, len(records))
+	for i, record := range records {
+		row := make(map[string]float64, len(features))
+		for _, feature := range features {
+			value, ok := record[feature]
+			if !ok {
+				problems = append(problems, session.ValidationProblem{Row: i + 1, Field: feature, Message: "missing feature"})
+				continue
+			}
+			switch value := value.(type) {
+			case nil:
+				row[feature] = math.NaN()
+			case json.Number:
+				number, err := value.Float64()
+				if err != nil {
+					problems = append(problems, session.ValidationProblem{Row: i + 1, Field: feature, Message: err.Error()})
+					continue
+				}
+				row[feature] = number
+			default:
+				problems = append(problems, session.ValidationProblem{Row: i + 1, Field: feature, Message: "value must be numeric"})
+			}
+		}
+		var extra []string
+		for field := range record {
+			if !slices.Contains(features, field) {
+				extra = append(extra, field)
+			}
+		}
+		slices.Sort(extra)
+		for _, field := range extra {
+			problems = append(problems, session.ValidationProblem{Row: i + 1, Field: field, Message: "not a feature of this model"})
+		}
+		rows[i] = row
+	}
+	return rows, problems
+}
+
+// nativeInference: Predicts every row of a dataset with the Go forest engine and writes the predictions CSV. Rows are scored if the dataset has the label column.
+// Problems with the model or dataset are reported as a *runner.Error, like the Python tool reports them.
+func nativeInference(model session.Model, dataset session.Dataset, predictions_path string) (runner.Inference, error) {
+	var inference runner.Inference
+	rf, err := forest_cache.Get(model.ForestPath)
+	if err != nil {
+		return inference, &runner.Error{Type: "model", Message: err.Error()}
+	}
+	columns, err := datafile.Columns(dataset.Path, dataset.Format)
+	if err != nil {
+		return inference, &runner.Error{Type: "dataset", Message: err.Error()}
+	}
+	label := ""
+	if slices.Contains(columns, model.InferName) {
+		label = model.InferName
+	}
+	rows, labels, err := forest.ReadDataset(dataset.Path, dataset.Format, model.Features, label)
+	if err != nil {
+		return inference, &runner.Error{Type: "dataset", Message: err.Error()}
+	}
+	predictions := rf.PredictBatch(rows)
+	f, err := os.Create(predictions_path)
+	if err != nil {
+		return inference, err
 	}
-	// Write to file
-	f, err := os.Create(path)
 	defer f.Close()
+	if err := forest.WritePredictions(f, rf.IsRegression(), predictions); err != nil {
+		return inference, err
+	}
+	inference.Rows = len(predictions)
+	if label == "" {
+		return inference, nil
+	}
+	if rf.IsRegression() {
+		targets := make([]float64, len(labels))
+		values := make([]float64, len(predictions))
+		for i, prediction := range predictions {
+			target, err := strconv.ParseFloat(labels[i], 64)
+			if err != nil {
+				return inference, &runner.Error{Type: "dataset", Message: fmt.Sprintf("row %d: target %q of column %q is not a number", i+1, labels[i], label)}
+			}
+			targets[i] = target
+			values[i] = prediction.Value
+		}
+		report := metrics.Regress(targets, values)
+		inference.RegressionMetrics = &report
+		return inference, nil
+	}
+	predicted := make([]string, len(predictions))
+	scores := make([]map[string]float64, len(predictions))
+	for i, prediction := range predictions {
+		predicted[i] = prediction.Class
+		scores[i] = prediction.VoteFractions()
+	}
+	report := metrics.Classify(model.Classes, labels, predicted, scores, model.Average)
+	inference.Metrics = &report
+	return inference, nil
+}
+
+// respondRunnerError: Reports a failed Python run to the client. Failures reported by the script itself carry their error object; a job cancelled before it started is a conflict.
+func respondRunnerError(c *gin.Context, err error) {
+	if errors.Is(err, errCancelled) {
+		c.JSON(http.StatusConflict, err.Error())
+		return
+	}
+	var runner_err *runner.Error
+	if errors.As(err, &runner_err) {
+		c.JSON(http.StatusUnprocessableEntity, runner_err)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, err.Error())
+}
+
+// removePartialModel: deletes the model files left behind by an unfinished training run. They are named after the run's task, so no catalogued model shares them.
+func removePartialModel(model_path string) {
+	for _, path := range session.TrainingOutputs(model_path) {
+		if err := removeFile(path); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// deleteTask: Cancels a queued or running task, killing any running process. A finished task is removed along with its working directory.
+func deleteTask(c *gin.Context) {
+	task, ok := current_session.GetTask(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "task not found, id: "+c.Param("id"))
+		return
+	}
+	if task.Finished() {
+		if err := task_workspace.Remove(task.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		current_session.RemoveTask(task.ID)
+		c.JSON(http.StatusOK, task)
+		return
+	}
+	if cancelled, ok := cancelTask(task.ID, "cancelled by request"); ok {
+		task = cancelled
+	}
+	c.JSON(http.StatusAccepted, task)
+}
+
+// cancelTask: Cancels a task's job. A job that never started is recorded as cancelled here, with reason as its error; a running job records its own cancellation.
+// Returns the recorded task if it was recorded here.
+func cancelTask(task_id string, reason string) (session.Task, bool) {
+	queued, found := job_scheduler.Cancel(task_id)
+	if !queued && found {
+		return session.Task{}, false
+	}
+	// Never started (or no longer known to the scheduler), so nothing will record the cancellation for us
+	task, err := current_session.UpdateTask(task_id, func(task *session.Task) error {
+		task.Error = reason
+		return task.Transition(session.TaskCancelled)
+	})
+	return task, err == nil
+}
+
+// errCancelled: the reply of a job a request is waiting on that was cancelled before it started.
+var errCancelled = errors.New("task was cancelled before it started")
+
+// awaitJob: Waits for the reply of a job a request is blocked on. If the request goes away first, the job is cancelled and the request's error returned.
+// The job's reply channel must have room for one reply, so the job never blocks on a reply nobody reads.
+func awaitJob[T any](ctx context.Context, task_id string, replies <-chan T) (T, error) {
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-ctx.Done():
+		cancelTask(task_id, "cancelled: the request waiting for it went away")
+		var none T
+		return none, ctx.Err()
+	}
+}
+
+// deleteDataset: Removes a dataset and its file. Datasets that models were trained on are kept unless force=true is given.
+func deleteDataset(c *gin.Context) {
+	dataset, ok := current_session.GetDataset(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "dataset not found, id: "+c.Param("id"))
+		return
+	}
+	if models := current_session.ModelsTrainedOn(dataset.ID); len(models) != 0 && c.Query("force") != "true" {
+		var model_ids []string
+		for _, model := range models {
+			model_ids = append(model_ids, model.ID)
+		}
+		c.JSON(http.StatusConflict, "dataset "+dataset.ID+" is referenced by models: "+strings.Join(model_ids, ", ")+"; use force=true to delete anyway")
+		return
+	}
+	if err := removeFile(dataset.Path); err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if dataset.Upload != nil {
+		if err := removeFile(dataset.Upload.PartPath); err != nil {
+			c.JSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	current_session.RemoveDataset(dataset.ID)
+	c.JSON(http.StatusOK, dataset)
+}
+
+// deleteModel: Removes a model, its file, every result produced with it, and its registry versions. A model in production is kept,
+// as is a file another model still uses.
+func deleteModel(c *gin.Context) {
+	model, ok := current_session.GetModel(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "model not found, id: "+c.Param("id"))
+		return
+	}
+	if err := current_session.UnregisterModel(model.ID); err != nil {
+		c.JSON(http.StatusConflict, err.Error())
+		return
+	}
+	for _, path := range []string{model.Path, model.ForestPath} {
+		if path == "" || current_session.ModelFileShared(path, model.ID) {
+			continue
+		}
+		if err := removeFile(path); err != nil {
+			c.JSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if !current_session.ModelFileShared(model.ForestPath, model.ID) {
+		forest_cache.Evict(model.ForestPath)
+	}
+	for _, result := range current_session.FindResults(model.ID, "") {
+		if err := removeResultFiles(result); err != nil {
+			log.Println(err)
+		}
+		current_session.RemoveResult(result.ID)
+	}
+	current_session.RemoveModel(model.ID)
+	c.JSON(http.StatusOK, model)
+}
+
+// deleteResult: Removes a single inference result.
+func deleteResult(c *gin.Context) {
+	result, ok := current_session.GetResult(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "result not found, id: "+c.Param("id"))
+		return
+	}
+	if err := removeResultFiles(result); err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	current_session.RemoveResult(result.ID)
+	c.JSON(http.StatusOK, result)
+}
+
+// removeResultFiles: Deletes the stored artifacts of a result.
+func removeResultFiles(result session.Result) error {
+	if result.ArtifactPath == "" {
+		return nil
+	}
+	return os.RemoveAll(result.ArtifactPath)
+}
+
+// removeFile: Deletes a file from the volume. A file that is already gone is not an error.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// getTasks: Returns the list of training tasks and their current state
+func getTasks(c *gin.Context) {
+	c.JSON(http.StatusOK, current_session.Tasks())
+}
+
+// getTask: Returns a single task, including its timestamps and captured output
+func getTask(c *gin.Context) {
+	task, ok := current_session.GetTask(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "task not found, id: "+c.Param("id"))
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+// uploadData: Uploads a provided dataset (.csv) to the microservice datastore and assigns it an ID.
+func uploadData(c *gin.Context) {
+	// Detect the dataset's format from its contents and profile it, then assign it an ID for future use.
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.String(http.StatusBadRequest, "Form error %s", err.Error())
+		return
+	}
+	filename := filepath.Base(file.Filename)
+	log.Printf("Uploading dataset %s...", filename)
+	part_path, checksum, err := savePart(file)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Error uploading file: %s", err.Error())
+		return
+	}
+	// The same file uploaded again is the same dataset
+	if existing, ok := current_session.FindDatasetByHash(checksum); ok {
+		if err := removeFile(part_path); err != nil {
+			log.Println(err)
+		}
+		c.JSON(http.StatusOK, existing)
+		return
+	}
+	path, err := storeDataset(part_path, checksum)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error storing file: %s", err.Error())
+		return
+	}
+	// Give our session the new dataset
+	var new_dataset session.Dataset
+	new_dataset.Name = datafile.TrimExtension(filename)
+	new_dataset.Path = path
+	new_dataset.SHA256 = checksum
+	new_dataset.CreatedAt = time.Now().UTC()
+	// Profile the columns so clients can pick features without guessing
+	format, schema, err := inspectDataset(c.Request.Context(), new_dataset.Path)
+	if err != nil {
+		removeUnsharedDataset(new_dataset.Path, checksum, "")
+		c.JSON(http.StatusUnprocessableEntity, "dataset could not be read: "+err.Error())
+		return
+	}
+	new_dataset.Format = format
+	new_dataset.ApplySchema(schema)
+	// Another upload of the same file may have been catalogued meanwhile; it is stored at the same path
+	new_dataset, _ = current_session.AddReadyDataset(new_dataset)
+	//Return good status
+	c.JSON(http.StatusOK, new_dataset)
+}
+
+// savePart: Streams a multipart upload into a part file next to the dataset store, hashing it on the way. Returns the part file's path and its hex SHA-256.
+func savePart(file *multipart.FileHeader) (string, string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", "", err
+	}
+	defer src.Close()
+	part, err := os.CreateTemp(uploads_root, "*.part")
+	if err != nil {
+		return "", "", err
+	}
+	defer part.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(part, hash), src); err != nil {
+		removeFile(part.Name())
+		return "", "", err
+	}
+	return part.Name(), hex.EncodeToString(hash.Sum(nil)), part.Close()
+}
+
+// storeDataset: Moves a complete part file into the dataset store under its SHA-256, so two different files never share a path.
+func storeDataset(part_path string, checksum string) (string, error) {
+	path := filepath.Join(datasets_root, checksum)
+	if err := os.Rename(part_path, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// verifyDataset: Checks that a dataset's file still has the SHA-256 it was stored under, and returns the dataset.
+// Datasets catalogued before checksums were recorded have theirs recorded instead.
+func verifyDataset(dataset session.Dataset) (session.Dataset, error) {
+	checksum, err := fileSHA256(dataset.Path)
 	if err != nil {
-		path = "./infer.toml"
-		f, err = os.Create(path)
-		log.Println("storage volume not available, writing to local directory")
+		return dataset, &runner.Error{Type: "dataset", Message: err.Error()}
+	}
+	if dataset.SHA256 == "" {
+		return current_session.UpdateDataset(dataset.ID, func(dataset *session.Dataset) error {
+			dataset.SHA256 = checksum
+			return nil
+		})
+	}
+	if checksum != dataset.SHA256 {
+		return dataset, &runner.Error{Type: "dataset", Message: "dataset " + dataset.ID + " failed its integrity check: stored with SHA-256 " + dataset.SHA256 + ", file now has " + checksum}
+	}
+	return dataset, nil
+}
+
+// removeUnsharedDataset: Deletes a stored dataset file that could not be read, unless a dataset other than the given one is catalogued with it,
+// such as an upload of the same file that finished first.
+func removeUnsharedDataset(path string, checksum string, dataset_id string) {
+	if current_session.DatasetFileShared(checksum, dataset_id) {
+		return
+	}
+	if err := removeFile(path); err != nil {
+		log.Println(err)
+	}
+}
+
+// inspectDataset: Detects the format of a complete dataset file and profiles it.
+func inspectDataset(ctx context.Context, path string) (string, *datafile.Schema, error) {
+	format, err := datafile.Detect(path)
+	if err != nil {
+		return "", nil, err
+	}
+	schema, err := profileFile(ctx, path, format)
+	return format, schema, err
+}
+
+// startUpload: Starts a chunked upload of a dataset too large to send in one request. The dataset is catalogued straight away as uploading;
+// its Upload section shows how many bytes have arrived, so an interrupted client knows where to resume.
+func startUpload(c *gin.Context) {
+	var upload_body session.ChunkedUploadConfig
+	if err := c.BindTOML(&upload_body); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	filename := filepath.Base(upload_body.Filename)
+	if filename == "." || filename == string(filepath.Separator) || upload_body.Size < 0 {
+		c.JSON(http.StatusBadRequest, "upload needs a file name and a size of at least 0")
+		return
+	}
+	part, err := os.CreateTemp(uploads_root, "*.part")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	part.Close()
+	now := time.Now().UTC()
+	dataset := current_session.AddDataset(session.Dataset{
+		Name:      datafile.TrimExtension(filename),
+		Status:    session.DatasetUploading,
+		Upload:    &session.UploadProgress{Filename: filename, TotalBytes: upload_body.Size, PartPath: part.Name(), UpdatedAt: now},
+		CreatedAt: now,
+	})
+	c.JSON(http.StatusCreated, dataset)
+}
+
+// uploadChunk: Streams one chunk of a chunked upload from the request body into the dataset's part file, starting at the offset query parameter.
+// The offset may not be past the bytes received so far. Anything already received after it is discarded, so a chunk can be resent, or resumed from ReceivedBytes after a dropped connection.
+func uploadChunk(c *gin.Context) {
+	if !claimUpload(c.Param("id")) {
+		c.JSON(http.StatusConflict, "another request is writing dataset "+c.Param("id"))
+		return
+	}
+	defer releaseUpload(c.Param("id"))
+	dataset, ok := current_session.GetDataset(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "dataset not found, id: "+c.Param("id"))
+		return
+	}
+	if dataset.Ready() || dataset.Upload == nil {
+		c.JSON(http.StatusConflict, "dataset "+dataset.ID+" is not being uploaded")
+		return
+	}
+	progress := *dataset.Upload
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, "offset must be a byte offset of at least 0")
+		return
+	}
+	if offset > progress.ReceivedBytes {
+		c.JSON(http.StatusConflict, fmt.Sprintf("offset %d is past the %d bytes received so far", offset, progress.ReceivedBytes))
+		return
+	}
+	if progress.TotalBytes > 0 && offset+c.Request.ContentLength > progress.TotalBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, fmt.Sprintf("chunk ends past the declared size of %d bytes", progress.TotalBytes))
+		return
+	}
+	received, write_err := writeChunk(progress.PartPath, offset, c.Request.Body, progress.TotalBytes)
+	// Whatever arrived before a failure is kept, so the client can resume after it
+	dataset, err = current_session.UpdateDataset(dataset.ID, func(dataset *session.Dataset) error {
+		progress.ReceivedBytes = received
+		progress.UpdatedAt = time.Now().UTC()
+		dataset.Upload = &progress
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(write_err, errChunkPastSize) {
+		c.JSON(http.StatusRequestEntityTooLarge, write_err.Error())
+		return
+	}
+	if write_err != nil {
+		c.JSON(http.StatusBadRequest, fmt.Sprintf("chunk incomplete, %d bytes received so far: %s", received, write_err))
+		return
+	}
+	c.JSON(http.StatusOK, dataset)
+}
+
+// errChunkPastSize: returned by writeChunk for a chunk that would take a part file past the upload's declared size
+var errChunkPastSize = errors.New("chunk ends past the declared size")
+
+// writeChunk: Writes a chunk to a part file at offset, discarding anything after it, and returns the size of the part file afterwards.
+// A chunk that would take the file past limit, if limit is set, is discarded entirely.
+func writeChunk(path string, offset int64, chunk io.Reader, limit int64) (int64, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return offset, err
+	}
+	defer f.Close()
+	if err := f.Truncate(offset); err != nil {
+		return offset, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+	if limit > 0 {
+		chunk = io.LimitReader(chunk, limit-offset+1)
+	}
+	written, err := io.Copy(f, chunk)
+	if limit > 0 && offset+written > limit {
+		if err := f.Truncate(offset); err != nil {
+			return offset + written, err
+		}
+		return offset, fmt.Errorf("%w of %d bytes", errChunkPastSize, limit)
+	}
+	if err != nil {
+		return offset + written, err
+	}
+	return offset + written, f.Close()
+}
+
+// finalizeUpload: Completes a chunked upload once the SHA-256 of the received file matches the client's. The file is moved into the dataset store,
+// then its format is detected and it is profiled like any other upload. A dataset that cannot be read is removed.
+func finalizeUpload(c *gin.Context) {
+	var finalize_body session.FinalizeUploadConfig
+	if err := c.BindTOML(&finalize_body); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	if !claimUpload(c.Param("id")) {
+		c.JSON(http.StatusConflict, "another request is writing dataset "+c.Param("id"))
+		return
+	}
+	defer releaseUpload(c.Param("id"))
+	dataset, ok := current_session.GetDataset(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "dataset not found, id: "+c.Param("id"))
+		return
+	}
+	if dataset.Ready() || dataset.Upload == nil {
+		c.JSON(http.StatusConflict, "dataset "+dataset.ID+" is not being uploaded")
+		return
+	}
+	progress := *dataset.Upload
+	if progress.TotalBytes > 0 && progress.ReceivedBytes != progress.TotalBytes {
+		c.JSON(http.StatusConflict, fmt.Sprintf("upload incomplete, %d of %d bytes received", progress.ReceivedBytes, progress.TotalBytes))
+		return
+	}
+	checksum, err := fileSHA256(progress.PartPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !strings.EqualFold(checksum, finalize_body.SHA256) {
+		c.JSON(http.StatusUnprocessableEntity, "checksum mismatch: received file has SHA-256 "+checksum)
+		return
+	}
+	// A file already in the store replaces the upload with the existing dataset
+	if existing, ok := current_session.FindDatasetByHash(checksum); ok {
+		if err := removeFile(progress.PartPath); err != nil {
+			log.Println(err)
+		}
+		current_session.RemoveDataset(dataset.ID)
+		c.JSON(http.StatusOK, existing)
+		return
+	}
+	path, err := storeDataset(progress.PartPath, checksum)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	format, schema, err := inspectDataset(c.Request.Context(), path)
+	if err != nil {
+		removeUnsharedDataset(path, checksum, dataset.ID)
+		current_session.RemoveDataset(dataset.ID)
+		c.JSON(http.StatusUnprocessableEntity, "dataset could not be read: "+err.Error())
+		return
+	}
+	// Another upload of the same file may have been catalogued meanwhile, in which case this one is dropped for it; both are stored at the same path
+	dataset, _, err = current_session.FinishUpload(dataset.ID, func(dataset *session.Dataset) error {
+		dataset.Path = path
+		dataset.SHA256 = checksum
+		dataset.Format = format
+		dataset.ApplySchema(schema)
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, dataset)
+}
+
+// claimUpload: Marks a chunked upload as being written, so chunks and finalizing never overlap. Returns false if another request already holds it.
+func claimUpload(id string) bool {
+	uploads_busy.mu.Lock()
+	defer uploads_busy.mu.Unlock()
+	if uploads_busy.ids[id] {
+		return false
+	}
+	uploads_busy.ids[id] = true
+	return true
+}
+
+// releaseUpload: Lets other requests write a chunked upload again.
+func releaseUpload(id string) {
+	uploads_busy.mu.Lock()
+	defer uploads_busy.mu.Unlock()
+	delete(uploads_busy.ids, id)
+}
+
+// uploadModel: Uploads a previously downloaded model to the microservice datastore, verifies it, and assigns it an ID
+// Portable exports (.forest.json) are validated before they are stored, and fill in the model's features, infer name and hyperparameters.
+func uploadModel(c *gin.Context) {
+	log.Println("Uploading Model...")
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.String(http.StatusBadRequest, "Form error %s", err.Error())
+		return
+	}
+	filename := filepath.Base(file.Filename)
+	log.Printf("Received model file %s", filename)
+	var rf *forest.Forest
+	if strings.HasSuffix(filename, forest.Extension) {
+		rf, err = decodeUploadedForest(file)
 		if err != nil {
-			log.Fatal(err)
+			c.JSON(http.StatusBadRequest, "invalid forest export: "+err.Error())
+			return
+		}
+	} else if !trusted_model_uploads {
+		// A joblib file is a pickle: the Python service would run any code in it when loading the model
+		c.JSON(http.StatusForbidden, "joblib model uploads are disabled, upload a portable "+forest.Extension+" export instead")
+		return
+	}
+	// Uploads of the same file name are stored side by side rather than overwriting each other
+	path, err := newModelPath(filename)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error uploading file: %s", err.Error())
+		return
+	}
+	if err := c.SaveUploadedFile(file, path); err != nil {
+		if err := removeFile(path); err != nil {
+			log.Println(err)
 		}
+		c.String(http.StatusBadRequest, "Error uploading file: %s", err.Error())
+		return
+	}
+	// New Model
+	var new_model session.Model
+	new_model.Name = filename
+	new_model.TrainedDataset = "unknown"
+	new_model.Path = path
+	new_model.Features = []string{"features"}
+	new_model.InferName = "unknown"
+	new_model.CreatedAt = time.Now().UTC()
+	if rf != nil {
+		new_model.Name = strings.TrimSuffix(filename, forest.Extension)
+		new_model.ApplyForest(rf, new_model.Path)
+	}
+	new_model = current_session.AddModel(new_model)
+	// Return good status coode
+	c.JSON(http.StatusOK, new_model)
+}
 
+// newModelPath: Reserves a file for an uploaded model under a unique name that keeps the uploaded name as its suffix, and so its extension.
+func newModelPath(filename string) (string, error) {
+	f, err := os.CreateTemp(models_root, "upload-*-"+filename)
+	if err != nil {
+		return "", err
 	}
-	_, err = f.Write(buf.Bytes())
+	defer f.Close()
+	return f.Name(), nil
+}
+
+// decodeUploadedForest: Reads and validates an uploaded forest export without trusting anything in it.
+func decodeUploadedForest(file *multipart.FileHeader) (*forest.Forest, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	return path
+	return forest.Decode(data)
+}
+
+// infer: infers on a defined dataset with a defined model, and returns the results.
+func infer(c *gin.Context) {
+	// take TOML with info on model and dataset. With only model, use the same dataset. Return results from inference
+	// Load up our chosen model (make sure it exists, get its full path on disk),
+	log.Println("Inferring with model...")
+	var infer_body session.InferConfig
+	if err := c.BindTOML(&infer_body); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	//load up our dataset and model, making sure both exist
+	dataset, ok := current_session.GetDataset(infer_body.DatasetID)
+	if !ok {
+		c.JSON(http.StatusNotFound, "dataset not found, id: "+infer_body.DatasetID)
+		return
+	}
+	if !dataset.Ready() {
+		c.JSON(http.StatusConflict, "dataset "+dataset.ID+" is still uploading")
+		return
+	}
+	model, ok := resolveModel(c, infer_body.ModelID)
+	if !ok {
+		return
+	}
+	task, dir, err := newTask("infer")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Models with a portable export are evaluated right here; only the Python fallback waits for a worker
+	predictions_path := predictionsPath(task.ID)
+	if inference, ok, err := nativePrediction(model, dataset, predictions_path); ok {
+		if _, err := current_session.UpdateTask(task.ID, func(task *session.Task) error { return task.Transition(session.TaskRunning) }); err != nil {
+			// Cancelled through DELETE /tasks while the forest was evaluated
+			if err := os.RemoveAll(filepath.Dir(predictions_path)); err != nil {
+				log.Println(err)
+			}
+			respondRunnerError(c, errCancelled)
+			return
+		}
+		result, err := recordInference(c.Request.Context(), task.ID, model, dataset, true, predictions_path, inference, runner.Output{}, err)
+		respondInference(c, result, err)
+		return
+	}
+
+	// Infer, store the results
+	type inferReply struct {
+		result session.Result
+		err    error
+	}
+	channel_status := make(chan inferReply, 1)
+	err = job_scheduler.Submit(scheduler.Job{ID: task.ID, Priority: scheduler.PriorityHigh, Run: func(ctx context.Context) {
+		log.Println("Starting inference...")
+		result, err := runInference(ctx, task.ID, model, dataset, dir, true)
+		channel_status <- inferReply{result, err}
+	}, OnCancel: func() { channel_status <- inferReply{err: errCancelled} }})
+	if err != nil {
+		rejectTask(task.ID, err)
+		c.JSON(http.StatusTooManyRequests, err.Error())
+		return
+	}
+	reply, err := awaitJob(c.Request.Context(), task.ID, channel_status)
+	if err != nil {
+		return
+	}
+	respondInference(c, reply.result, reply.err)
+}
+
+// respondInference: Returns the results of an inference, or what went wrong with it.
+func respondInference(c *gin.Context, result session.Result, err error) {
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+	// Return the inference results and a good status code
+	c.JSON(http.StatusOK, session.InferenceResponse{ResultID: result.ID, Metrics: result.Metrics, RegressionMetrics: result.RegressionMetrics})
+}
+
+// startBatchInference: Queues a batch inference job that keeps every row's prediction, and returns the ID of the task tracking it.
+// Once the task succeeds, its ResultID names the result holding the predictions.
+func startBatchInference(c *gin.Context) {
+	log.Println("Queueing batch inference...")
+	var infer_body session.InferConfig
+	if err := c.BindTOML(&infer_body); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	dataset, ok := current_session.GetDataset(infer_body.DatasetID)
+	if !ok {
+		c.JSON(http.StatusNotFound, "dataset not found, id: "+infer_body.DatasetID)
+		return
+	}
+	if !dataset.Ready() {
+		c.JSON(http.StatusConflict, "dataset "+dataset.ID+" is still uploading")
+		return
+	}
+	model, ok := resolveModel(c, infer_body.ModelID)
+	if !ok {
+		return
+	}
+	task, dir, err := newTask("batch_infer")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = job_scheduler.Submit(scheduler.Job{ID: task.ID, Priority: scheduler.PriorityNormal, Run: func(ctx context.Context) {
+		runInference(ctx, task.ID, model, dataset, dir, false)
+	}})
+	if err != nil {
+		rejectTask(task.ID, err)
+		c.JSON(http.StatusTooManyRequests, err.Error())
+		return
+	}
+	c.JSON(http.StatusAccepted, session.TaskResponse{Response: "batch inference queued", TaskID: task.ID})
+}
+
+// runInference: Predicts every row of a dataset on a scheduler worker and records the run as a result, with its metrics and predictions.
+// If labelled is set, the dataset must have the model's label column so the predictions can be scored.
+func runInference(ctx context.Context, task_id string, model session.Model, dataset session.Dataset, dir string, labelled bool) (session.Result, error) {
+	current_session.UpdateTask(task_id, func(task *session.Task) error { return task.Transition(session.TaskRunning) })
+	predictions_path := predictionsPath(task_id)
+	inference, output, err := predictDataset(ctx, model, dataset, dir, predictions_path)
+	return recordInference(ctx, task_id, model, dataset, labelled, predictions_path, inference, output, err)
+}
+
+// predictionsPath: Where the predictions of an inference task are written, in the task's artifact directory.
+func predictionsPath(task_id string) string {
+	return filepath.Join(results_root, task_id, "predictions.csv")
+}
+
+// recordInference: Finishes a running inference task, recording a result for its predictions, or failing the task and removing its artifacts.
+func recordInference(ctx context.Context, task_id string, model session.Model, dataset session.Dataset, labelled bool, predictions_path string, inference runner.Inference, output runner.Output, err error) (session.Result, error) {
+	artifact_dir := filepath.Dir(predictions_path)
+	if err == nil && labelled && inference.Metrics == nil && inference.RegressionMetrics == nil {
+		err = &runner.Error{Type: "dataset", Message: "dataset " + dataset.ID + " has no label column " + model.InferName + " to score against"}
+	}
+	if err != nil {
+		log.Println(err)
+		failTask(ctx, task_id, output, err)
+		if err := os.RemoveAll(artifact_dir); err != nil {
+			log.Println(err)
+		}
+		return session.Result{}, err
+	}
+	result := current_session.AddResult(session.Result{ModelID: model.ID, DatasetID: dataset.ID, TaskID: task_id, Rows: inference.Rows, Metrics: inference.Metrics, RegressionMetrics: inference.RegressionMetrics, ArtifactPath: artifact_dir, PredictionsPath: predictions_path, CreatedAt: time.Now().UTC()})
+	succeedTask(task_id, output, func(task *session.Task) {
+		task.ModelID = model.ID
+		task.ResultID = result.ID
+	})
+	return result, nil
+}
+
+// predictDataset: Writes the predictions CSV for a dataset and scores it if it is labelled.
+// The Python service is only started if the model or dataset cannot be evaluated in-process.
+func predictDataset(ctx context.Context, model session.Model, dataset session.Dataset, dir string, predictions_path string) (runner.Inference, runner.Output, error) {
+	if inference, ok, err := nativePrediction(model, dataset, predictions_path); ok {
+		return inference, runner.Output{}, err
+	}
+	infertomlpath, err := generateInferenceTOML(dir, dataset.Path, dataset.Format, model.Path, predictions_path, model.InferName, "infer", model.Features, model.Average)
+	if err != nil {
+		return runner.Inference{}, runner.Output{}, err
+	}
+	result, output, err := runner.Run(ctx, infertomlpath, dir)
+	if err != nil {
+		return runner.Inference{}, output, err
+	}
+	if result.Inference == nil {
+		return runner.Inference{}, output, errors.New("inference produced no results")
+	}
+	return *result.Inference, output, nil
+}
+
+// nativePrediction: Writes the predictions CSV for a dataset with the Go forest engine, if the model has a portable export and the dataset a format Go reads.
+// Reports false if the Python service has to be started instead: for models without an export, for Parquet datasets, or if the export cannot be used.
+func nativePrediction(model session.Model, dataset session.Dataset, predictions_path string) (runner.Inference, bool, error) {
+	if err := os.MkdirAll(filepath.Dir(predictions_path), 0777); err != nil {
+		return runner.Inference{}, true, err
+	}
+	if model.Path == model.ForestPath && !datafile.Native(dataset.Format) {
+		return runner.Inference{}, true, &runner.Error{Type: "dataset", Message: "model " + model.ID + " was uploaded as a portable export, which cannot read " + dataset.Format + " datasets"}
+	}
+	if model.ForestPath == "" || !datafile.Native(dataset.Format) {
+		return runner.Inference{}, false, nil
+	}
+	inference, err := nativeInference(model, dataset, predictions_path)
+	if err == nil || model.Path == model.ForestPath {
+		// Portable-only models have no joblib file for Python to fall back on
+		return inference, true, err
+	}
+	log.Printf("native inference unavailable for model %s, falling back to python: %s", model.ID, err)
+	return runner.Inference{}, false, nil
+}
+
+// getResultPredictions: Returns the per-row predictions CSV of a batch inference result
+func getResultPredictions(c *gin.Context) {
+	result, ok := current_session.GetResult(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, "result not found, id: "+c.Param("id"))
+		return
+	}
+	if result.PredictionsPath == "" {
+		c.JSON(http.StatusNotFound, "result "+result.ID+" has no stored predictions")
+		return
+	}
+	if _, err := os.Stat(result.PredictionsPath); err != nil {
+		c.JSON(http.StatusNotFound, "predictions file not available, id: "+result.ID)
+		return
+	}
+	c.FileAttachment(result.PredictionsPath, result.ID+"-predictions.csv")
+}
+
+func generateTrainingTOML(dir string, filepath string, format string, modelpath string, exportpath string, infername string, tasktype string, config []string, depth int, trees int, samplesplit int, fraction float64, datasplit float64, showunoptmizied bool, average string, modeltype string) (string, error) {
+	trainingToml := session.RandomForestTrainingConfig{TaskType: tasktype, FilePath: filepath, InputFormat: format, Features: config, InferenceName: infername, ModelPath: modelpath, ExportPath: exportpath, NTrees: trees, SampleSplit: samplesplit, MaxDepth: depth, FeaturesFraction: fraction, DataSplit: datasplit, ShowUnoptimzied: showunoptmizied, Average: average, ModelType: modeltype}
+	return writeTOML(dir, "train.toml", trainingToml)
+}
+
+func generateInferenceTOML(dir string, filepath string, format string, modelpath string, predictionspath string, infername string, tasktype string, config []string, average string) (string, error) {
+	trainingToml := session.RandomForestTrainingConfig{TaskType: tasktype, FilePath: filepath, InputFormat: format, Features: config, InferenceName: infername, ModelPath: modelpath, PredictionsPath: predictionspath, Average: average}
+	return writeTOML(dir, "infer.toml", trainingToml)
+}
+
+func generateTreesTOML(dir string, modelpath string, tasktype string) (string, error) {
+	treesTOML := session.RandomForestTrainingConfig{TaskType: tasktype, ModelPath: modelpath}
+	return writeTOML(dir, "trees.toml", treesTOML)
+}
+
+func generateProfileTOML(dir string, filepath string, format string, tasktype string) (string, error) {
+	profileTOML := session.RandomForestTrainingConfig{TaskType: tasktype, FilePath: filepath, InputFormat: format}
+	return writeTOML(dir, "profile.toml", profileTOML)
+}
+
+// writeTOML: Encodes a config for the training script into a task's working directory and returns the file path.
+func writeTOML(dir string, name string, config session.RandomForestTrainingConfig) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(config); err != nil {
+		return "", err
+	}
+	// Write to file
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0666); err != nil {
+		return "", err
+	}
+	return path, nil
 }
 
 // main: our main function
@@ -445,10 +1806,66 @@ func main() {
 	if volumePath == "" {
 		volumePath = "/storage"
 	}
+	// Scheduler limits: how many Python jobs run at once, and how many may wait for a worker
+	workers, err := strconv.Atoi(os.Getenv("SCHEDULER_WORKERS"))
+	if err != nil {
+		workers = 2
+	}
+	queue_size, err := strconv.Atoi(os.Getenv("SCHEDULER_QUEUE_SIZE"))
+	if err != nil {
+		queue_size = 16
+	}
+	job_scheduler = scheduler.New(workers, queue_size)
+	// Task working directories: kept for auditing, removed once older than the retention period
+	task_workspace, err = workspace.New(filepath.Join(volumePath, "tasks"))
+	if err != nil {
+		log.Println("storage volume not available, writing task directories to local directory")
+		task_workspace, err = workspace.New("./tasks")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	// Result artifacts live outside the task directories, so they outlast task retention
+	results_root = filepath.Join(volumePath, "results")
+	if err := os.MkdirAll(results_root, 0777); err != nil {
+		log.Println("storage volume not available, writing results to local directory")
+		results_root = "./results"
+	}
+	models_root = filepath.Join(volumePath, "models")
+	if err := os.MkdirAll(models_root, 0777); err != nil {
+		log.Println("storage volume not available, writing models to local directory")
+		models_root = "."
+	}
+	datasets_root = filepath.Join(volumePath, "datasets")
+	if err := os.MkdirAll(datasets_root, 0777); err != nil {
+		log.Println("storage volume not available, writing datasets to local directory")
+		datasets_root = "."
+	}
+	// Chunked uploads are assembled next to the dataset store, so finalizing only has to rename them
+	uploads_root = filepath.Join(volumePath, "uploads")
+	if err := os.MkdirAll(uploads_root, 0777); err != nil {
+		log.Println("storage volume not available, assembling uploads in local directory")
+		uploads_root = "./uploads"
+		if err := os.MkdirAll(uploads_root, 0777); err != nil {
+			log.Println(err)
+		}
+	}
+	retention_hours, err := strconv.Atoi(os.Getenv("TASK_RETENTION_HOURS"))
+	if err != nil {
+		retention_hours = 168
+	}
+	// Chunked uploads nobody has added to or finalized for this long are abandoned
+	upload_expiry_hours, err := strconv.Atoi(os.Getenv("UPLOAD_EXPIRY_HOURS"))
+	if err != nil {
+		upload_expiry_hours = 24
+	}
+	trusted_model_uploads, _ = strconv.ParseBool(os.Getenv("TRUSTED_MODEL_UPLOADS"))
 	//Create Router
 	router := setupRouter()
 	// Set up session variables
 	current_session.Setup(volumePath)
+	defer current_session.Close()
+	go collectTaskDirs(time.Duration(retention_hours)*time.Hour, time.Duration(upload_expiry_hours)*time.Hour, time.Hour)
 	os.Setenv("PATH", os.Getenv("PATH")+":/home/oddforest/.pyenv/shims/")
 	//Router Run
 	router.Run(":9001")
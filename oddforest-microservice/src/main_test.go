@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gin-gonic/gin"
+	datafile "intel.com/oddforest-microservice/datafile"
+	forest "intel.com/oddforest-microservice/forest"
+	session "intel.com/oddforest-microservice/session"
+)
+
+// newTestServer: points the service's session and file stores at a fresh volume and returns its router. The globals are shared, so tests in this package do not run in parallel.
+func newTestServer(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	volume := t.TempDir()
+	current_session = session.Session{}
+	current_session.Setup(volume)
+	t.Cleanup(func() { current_session.Close() })
+	forest_cache = forest.Cache{}
+	models_root = filepath.Join(volume, "models")
+	datasets_root = filepath.Join(volume, "datasets")
+	uploads_root = filepath.Join(volume, "uploads")
+	if err := os.MkdirAll(uploads_root, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	return setupRouter()
+}
+
+// serve: sends a request to the router and returns the recorded response.
+func serve(router *gin.Engine, method string, url string, content_type string, body io.Reader) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, url, body)
+	if content_type != "" {
+		request.Header.Set("Content-Type", content_type)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// decodeBody: decodes a JSON response body, failing the test if it cannot.
+func decodeBody[T any](t *testing.T, recorder *httptest.ResponseRecorder) T {
+	t.Helper()
+	var body T
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s: %v", recorder.Body, err)
+	}
+	return body
+}
+
+// addTestDataset: stores a CSV file and catalogues it as a ready dataset.
+func addTestDataset(t *testing.T, name string, csv string) session.Dataset {
+	t.Helper()
+	path := filepath.Join(datasets_root, name)
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dataset, _ := current_session.AddReadyDataset(session.Dataset{Name: name, Path: path, Format: datafile.FormatCSV, SHA256: name})
+	return dataset
+}
+
+func TestTrainingRequestValidation(t *testing.T) {
+	router := newTestServer(t)
+	dataset := addTestDataset(t, "churn", "x,y,name,label\n1,2,a,yes\n2,3,b,no\n3,4,c,yes\n")
+	valid := session.TrainingConfig{Name: "churn", DatasetID: dataset.ID, InferName: "label", Features: []string{"x", "y"}, DataSplit: 0.8}
+	if _, problems := validateTrainingRequest(context.Background(), valid); len(problems) != 0 {
+		t.Fatalf("valid request has problems %+v", problems)
+	}
+
+	tests := []struct {
+		name     string
+		change   func(*session.TrainingConfig)
+		problems []session.ValidationProblem
+	}{
+		{
+			"data_split of 0", func(config *session.TrainingConfig) { config.DataSplit = 0 },
+			[]session.ValidationProblem{{Field: "data_split", Message: "must be between 0 and 1, the share of rows the forest is grown on"}},
+		},
+		{
+			"data_split of 1", func(config *session.TrainingConfig) { config.DataSplit = 1 },
+			[]session.ValidationProblem{{Field: "data_split", Message: "must be between 0 and 1, the share of rows the forest is grown on"}},
+		},
+		{
+			"negative data_split", func(config *session.TrainingConfig) { config.DataSplit = -0.5 },
+			[]session.ValidationProblem{{Field: "data_split", Message: "must be between 0 and 1, the share of rows the forest is grown on"}},
+		},
+		{
+			"missing feature column", func(config *session.TrainingConfig) { config.Features = []string{"x", "z"} },
+			[]session.ValidationProblem{{Field: "z", Message: "feature column not found in dataset " + dataset.ID}},
+		},
+		{
+			"text feature column", func(config *session.TrainingConfig) { config.Features = []string{"name", "y"} },
+			[]session.ValidationProblem{{Field: "name", Message: "feature column is string, not numeric"}},
+		},
+		{
+			"missing label column", func(config *session.TrainingConfig) { config.InferName = "churned" },
+			[]session.ValidationProblem{{Field: "churned", Message: "label column not found in dataset " + dataset.ID}},
+		},
+		{
+			"label listed as a feature", func(config *session.TrainingConfig) { config.Features = []string{"x", "label"} },
+			[]session.ValidationProblem{
+				{Field: "label", Message: "label column is also listed as a feature"},
+				{Field: "label", Message: "feature column is string, not numeric"},
+			},
+		},
+		{
+			"missing dataset", func(config *session.TrainingConfig) { config.DatasetID = "d99" },
+			[]session.ValidationProblem{{Field: "dataset_id", Message: "dataset not found, id: d99"}},
+		},
+		{
+			"every problem at once", func(config *session.TrainingConfig) { config.DataSplit, config.Features = 2, []string{"z"} },
+			[]session.ValidationProblem{
+				{Field: "data_split", Message: "must be between 0 and 1, the share of rows the forest is grown on"},
+				{Field: "z", Message: "feature column not found in dataset " + dataset.ID},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := valid
+			config.Features = slices.Clone(valid.Features)
+			test.change(&config)
+			var body bytes.Buffer
+			if err := toml.NewEncoder(&body).Encode(config); err != nil {
+				t.Fatal(err)
+			}
+			recorder := serve(router, http.MethodPost, "/train", "application/toml", &body)
+			if recorder.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status %d, want %d: %s", recorder.Code, http.StatusUnprocessableEntity, recorder.Body)
+			}
+			if response := decodeBody[session.ValidationResponse](t, recorder); !slices.Equal(response.Problems, test.problems) {
+				t.Errorf("problems %+v, want %+v", response.Problems, test.problems)
+			}
+		})
+	}
+	if tasks := current_session.Tasks(); len(tasks) != 0 {
+		t.Errorf("invalid requests started tasks %+v", tasks)
+	}
+}
+
+// predictForestJSON: a single stump predicting "yes" from x of 1.5 on, and "no" below it or when x is missing.
+const predictForestJSON = `{
+	"format_version": 2,
+	"features": ["x", "y"],
+	"infer_name": "label",
+	"classes": ["no", "yes"],
+	"hyperparameters": {"n_trees": 1, "max_depth": 1, "min_samples_split": 2, "x_features_fraction": 1, "x_obs_fraction": 1},
+	"trees": [{"feature": "x", "threshold": 1.5, "left": {"prediction": "no"}, "right": {"prediction": "yes"}}]
+}`
+
+func TestPredictRecords(t *testing.T) {
+	router := newTestServer(t)
+	path := filepath.Join(models_root, "t1"+forest.Extension)
+	if err := os.WriteFile(path, []byte(predictForestJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := current_session.AddModel(session.Model{Name: "churn", Features: []string{"x", "y"}, InferName: "label", ForestPath: path})
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		classes  []string
+		problems []session.ValidationProblem
+	}{
+		{"single record", `{"x": 2, "y": 0}`, http.StatusOK, []string{"yes"}, nil},
+		{"records in order, null as missing", `[{"x": 1, "y": 0}, {"x": 2, "y": null}, {"x": null, "y": 5}]`, http.StatusOK, []string{"no", "yes", "yes"}, nil},
+		{"missing feature", `[{"x": 1, "y": 0}, {"x": 1}]`, http.StatusUnprocessableEntity, nil, []session.ValidationProblem{{Row: 2, Field: "y", Message: "missing feature"}}},
+		{
+			"extra fields, sorted", `{"x": 1, "y": 0, "z": 3, "a": 4}`, http.StatusUnprocessableEntity, nil,
+			[]session.ValidationProblem{{Row: 1, Field: "a", Message: "not a feature of this model"}, {Row: 1, Field: "z", Message: "not a feature of this model"}},
+		},
+		{
+			"non-numeric fields", `{"x": "1", "y": true}`, http.StatusUnprocessableEntity, nil,
+			[]session.ValidationProblem{{Row: 1, Field: "x", Message: "value must be numeric"}, {Row: 1, Field: "y", Message: "value must be numeric"}},
+		},
+		{
+			"every row's problems", `[{"y": 0}, {"x": [1], "y": 0, "id": 7}]`, http.StatusUnprocessableEntity, nil,
+			[]session.ValidationProblem{
+				{Row: 1, Field: "x", Message: "missing feature"},
+				{Row: 2, Field: "x", Message: "value must be numeric"},
+				{Row: 2, Field: "id", Message: "not a feature of this model"},
+			},
+		},
+		{"no records", `[]`, http.StatusBadRequest, nil, nil},
+		{"not JSON", `{"x": 1,`, http.StatusBadRequest, nil, nil},
+		{"not an object", `42`, http.StatusBadRequest, nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve(router, http.MethodPost, "/models/"+model.ID+"/predict", "application/json", bytes.NewBufferString(test.body))
+			if recorder.Code != test.status {
+				t.Fatalf("status %d, want %d: %s", recorder.Code, test.status, recorder.Body)
+			}
+			switch test.status {
+			case http.StatusOK:
+				var classes []string
+				for _, prediction := range decodeBody[session.PredictResponse](t, recorder).Predictions {
+					classes = append(classes, prediction.Class)
+				}
+				if !slices.Equal(classes, test.classes) {
+					t.Errorf("classes %v, want %v", classes, test.classes)
+				}
+			case http.StatusUnprocessableEntity:
+				if response := decodeBody[session.ValidationResponse](t, recorder); !slices.Equal(response.Problems, test.problems) {
+					t.Errorf("problems %+v, want %+v", response.Problems, test.problems)
+				}
+			}
+		})
+	}
+}
+
+// hideLength: hides a body's length from the request, as with a chunked transfer, so only the part file's limit stops an overlong chunk.
+type hideLength struct {
+	io.Reader
+}
+
+func TestUploadChunks(t *testing.T) {
+	router := newTestServer(t)
+	recorder := serve(router, http.MethodPost, "/data/uploads", "application/toml", strings.NewReader("filename = \"churn.csv\"\nsize = 10\n"))
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("starting upload: status %d: %s", recorder.Code, recorder.Body)
+	}
+	dataset := decodeBody[session.Dataset](t, recorder)
+
+	// Each chunk builds on the part file the one before it left
+	tests := []struct {
+		name   string
+		offset string
+		body   io.Reader
+		status int
+		part   string
+	}{
+		{"first chunk", "0", strings.NewReader("abcdef"), http.StatusOK, "abcdef"},
+		{"resent from an earlier offset", "3", strings.NewReader("XYZ"), http.StatusOK, "abcXYZ"},
+		{"offset past the bytes received", "7", strings.NewReader("q"), http.StatusConflict, "abcXYZ"},
+		{"negative offset", "-1", strings.NewReader("q"), http.StatusBadRequest, "abcXYZ"},
+		{"declared length past the size", "6", strings.NewReader("0123456789"), http.StatusRequestEntityTooLarge, "abcXYZ"},
+		{"streamed past the size", "6", hideLength{strings.NewReader("0123456")}, http.StatusRequestEntityTooLarge, "abcXYZ"},
+		{"last chunk", "6", hideLength{strings.NewReader("wxyz")}, http.StatusOK, "abcXYZwxyz"},
+	}
+	for _, test := range tests {
+		recorder := serve(router, http.MethodPut, "/data/uploads/"+dataset.ID+"?offset="+test.offset, "application/octet-stream", test.body)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status %d, want %d: %s", test.name, recorder.Code, test.status, recorder.Body)
+		}
+		dataset, _ = current_session.GetDataset(dataset.ID)
+		part, err := os.ReadFile(dataset.Upload.PartPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(part) != test.part || dataset.Upload.ReceivedBytes != int64(len(test.part)) {
+			t.Errorf("%s: part file %q with %d bytes received, want %q", test.name, part, dataset.Upload.ReceivedBytes, test.part)
+		}
+	}
+}
+
+func TestExpireUploads(t *testing.T) {
+	newTestServer(t)
+	now := time.Now().UTC()
+	upload := func(name string, updated time.Time) session.Dataset {
+		part, err := os.CreateTemp(uploads_root, "*.part")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Close()
+		return current_session.AddDataset(session.Dataset{
+			Name:   name,
+			Status: session.DatasetUploading,
+			Upload: &session.UploadProgress{Filename: name + ".csv", PartPath: part.Name(), UpdatedAt: updated},
+		})
+	}
+	stale := upload("stale", now.Add(-2*time.Hour))
+	fresh := upload("fresh", now.Add(-30*time.Minute))
+	// A request writing a chunk right now holds the upload, however long it went without one before
+	busy := upload("busy", now.Add(-2*time.Hour))
+	claimUpload(busy.ID)
+	defer releaseUpload(busy.ID)
+	ready := addTestDataset(t, "ready", "x,label\n1,a\n")
+
+	expireUploads(time.Hour)
+	if _, ok := current_session.GetDataset(stale.ID); ok {
+		t.Errorf("stale upload %s still catalogued", stale.ID)
+	}
+	if _, err := os.Stat(stale.Upload.PartPath); !os.IsNotExist(err) {
+		t.Errorf("stale upload's part file not removed: %v", err)
+	}
+	for _, dataset := range []session.Dataset{fresh, busy, ready} {
+		if _, ok := current_session.GetDataset(dataset.ID); !ok {
+			t.Errorf("%s dataset %s expired", dataset.Name, dataset.ID)
+		}
+		if dataset.Upload != nil {
+			if _, err := os.Stat(dataset.Upload.PartPath); err != nil {
+				t.Errorf("%s upload's part file: %v", dataset.Name, err)
+			}
+		}
+	}
+}
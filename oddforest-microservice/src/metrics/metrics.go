@@ -0,0 +1,254 @@
+package metrics
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// Averaging methods for the headline precision, recall and F1 of a report.
+// Binary reports the scores of the positive class and only applies to two classes; macro is the unweighted mean over every class,
+// weighted the mean weighted by each class's support, and micro pools every row (so it equals accuracy).
+const (
+	AverageBinary   = "binary"
+	AverageMacro    = "macro"
+	AverageMicro    = "micro"
+	AverageWeighted = "weighted"
+)
+
+// Averages: every averaging method Classify accepts.
+var Averages = []string{AverageBinary, AverageMacro, AverageMicro, AverageWeighted}
+
+// Report: how a classifier scored against a labelled dataset. Computed the same way by the Python tool's metrics.py.
+// Average says how Precision, Recall and F1 were combined over the classes.
+type Report struct {
+	Accuracy        float64                 `json:"accuracy"`
+	Precision       float64                 `json:"precision"`
+	Recall          float64                 `json:"recall"`
+	F1              float64                 `json:"f1"`
+	ROCAUC          *float64                `json:"roc_auc"`
+	Average         string                  `json:"average"`
+	PositiveClass   string                  `json:"positive_class,omitempty"`
+	Classes         []string                `json:"classes"`
+	ConfusionMatrix [][]int                 `json:"confusion_matrix"`
+	PerClass        map[string]ClassMetrics `json:"per_class"`
+}
+
+// ClassMetrics: the one-vs-rest scores of a single class. Support is the number of rows labelled with the class.
+type ClassMetrics struct {
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"`
+}
+
+// Training: the reports produced by a training run. The unoptimized entries are nil unless show_unoptimized was set.
+type Training struct {
+	UnoptimizedValidation *Report `json:"unoptimized_validation"`
+	UnoptimizedTest       *Report `json:"unoptimized_test"`
+	Validation            *Report `json:"validation"`
+	Test                  *Report `json:"test"`
+}
+
+// Regression: how a regression forest scored against a dataset with numeric targets. Computed the same way by the Python tool's metrics.py.
+type Regression struct {
+	RMSE float64 `json:"rmse"`
+	MAE  float64 `json:"mae"`
+	R2   float64 `json:"r2"`
+}
+
+// RegressionTraining: the reports produced by training a regression forest. The unoptimized entries are nil unless show_unoptimized was set.
+type RegressionTraining struct {
+	UnoptimizedValidation *Regression `json:"unoptimized_validation"`
+	UnoptimizedTest       *Regression `json:"unoptimized_test"`
+	Validation            *Regression `json:"validation"`
+	Test                  *Regression `json:"test"`
+}
+
+// Regress: scores predicted values against the true targets. R2 of constant targets is 1 for a perfect fit and 0 otherwise, rather than undefined.
+func Regress(targets []float64, predictions []float64) Regression {
+	var report Regression
+	if len(targets) == 0 {
+		return report
+	}
+	mean := 0.0
+	for _, target := range targets {
+		mean += target
+	}
+	mean /= float64(len(targets))
+	squared_error, absolute_error, total_variance := 0.0, 0.0, 0.0
+	for i, target := range targets {
+		residual := target - predictions[i]
+		squared_error += residual * residual
+		absolute_error += math.Abs(residual)
+		total_variance += (target - mean) * (target - mean)
+	}
+	n := float64(len(targets))
+	report.RMSE = math.Sqrt(squared_error / n)
+	report.MAE = absolute_error / n
+	switch {
+	case total_variance != 0:
+		report.R2 = 1 - squared_error/total_variance
+	case squared_error == 0:
+		report.R2 = 1
+	}
+	return report
+}
+
+// Classify: scores predicted classes against the true labels. The classes are those the model was trained on, along with any other label seen in either, sorted;
+// passing the model's classes keeps the averaging and positive class the same however few of them the scored rows happen to hold.
+// average is one of Averages. An empty average, or binary with more than two classes, uses binary for two classes and macro otherwise; the positive class is the last class in sorted order.
+// scores holds, for each row, the fraction of trees that voted for each class; ROCAUC is left nil without them, or when no class has both positive and negative rows.
+// The confusion matrix is indexed [true class][predicted class], in the order of Classes.
+func Classify(model_classes []string, labels []string, predictions []string, scores []map[string]float64, average string) Report {
+	classes := slices.Concat(model_classes, labels, predictions)
+	sort.Strings(classes)
+	classes = slices.Compact(classes)
+	index := make(map[string]int, len(classes))
+	for i, class := range classes {
+		index[class] = i
+	}
+
+	report := Report{Classes: classes, PerClass: make(map[string]ClassMetrics, len(classes))}
+	report.ConfusionMatrix = make([][]int, len(classes))
+	for i := range report.ConfusionMatrix {
+		report.ConfusionMatrix[i] = make([]int, len(classes))
+	}
+	correct := 0
+	for i, label := range labels {
+		report.ConfusionMatrix[index[label]][index[predictions[i]]]++
+		if label == predictions[i] {
+			correct++
+		}
+	}
+	report.Accuracy = ratio(correct, len(labels))
+
+	for i, class := range classes {
+		true_positives := report.ConfusionMatrix[i][i]
+		predicted, support := 0, 0
+		for j := range classes {
+			predicted += report.ConfusionMatrix[j][i]
+			support += report.ConfusionMatrix[i][j]
+		}
+		report.PerClass[class] = ClassMetrics{
+			Precision: ratio(true_positives, predicted),
+			Recall:    ratio(true_positives, support),
+			F1:        ratio(2*true_positives, predicted+support),
+			Support:   support,
+		}
+	}
+
+	if average == "" || average == AverageBinary {
+		average = AverageBinary
+		if len(classes) > 2 {
+			average = AverageMacro
+		}
+	}
+	report.Average = average
+	switch average {
+	case AverageBinary:
+		if len(classes) != 0 {
+			report.PositiveClass = classes[len(classes)-1]
+			positive := report.PerClass[report.PositiveClass]
+			report.Precision, report.Recall, report.F1 = positive.Precision, positive.Recall, positive.F1
+			if scores != nil {
+				if auc, ok := classAUC(labels, scores, report.PositiveClass); ok {
+					report.ROCAUC = &auc
+				}
+			}
+		}
+		return report
+	case AverageMicro:
+		// Every row is one prediction, so pooled precision and recall are both the share of rows predicted correctly
+		report.Precision, report.Recall, report.F1 = report.Accuracy, report.Accuracy, report.Accuracy
+	default:
+		total := 0.0
+		for _, class := range classes {
+			weight := classWeight(report.PerClass[class], average)
+			report.Precision += weight * report.PerClass[class].Precision
+			report.Recall += weight * report.PerClass[class].Recall
+			report.F1 += weight * report.PerClass[class].F1
+			total += weight
+		}
+		if total != 0 {
+			report.Precision, report.Recall, report.F1 = report.Precision/total, report.Recall/total, report.F1/total
+		}
+	}
+	if scores != nil {
+		// One-vs-rest AUC averaged over the classes it is defined for, weighted by support for weighted averaging
+		auc_sum, total := 0.0, 0.0
+		for _, class := range classes {
+			if auc, ok := classAUC(labels, scores, class); ok {
+				weight := classWeight(report.PerClass[class], average)
+				auc_sum += weight * auc
+				total += weight
+			}
+		}
+		if total != 0 {
+			auc := auc_sum / total
+			report.ROCAUC = &auc
+		}
+	}
+	return report
+}
+
+// classWeight: the weight of a class when averaging over classes.
+func classWeight(class ClassMetrics, average string) float64 {
+	if average == AverageWeighted {
+		return float64(class.Support)
+	}
+	return 1
+}
+
+// classAUC: the one-vs-rest ROC AUC of a class, scoring each row by its vote fraction for the class. Not defined unless the class has both positive and negative rows.
+func classAUC(labels []string, scores []map[string]float64, class string) (float64, bool) {
+	positives := make([]bool, len(labels))
+	class_scores := make([]float64, len(labels))
+	for i, label := range labels {
+		positives[i] = label == class
+		class_scores[i] = scores[i][class]
+	}
+	return AUC(positives, class_scores)
+}
+
+// AUC: the area under the ROC curve for binary outcomes and their scores, computed as the Mann-Whitney statistic with tied scores counted as half.
+// Not defined unless there is at least one positive and one negative.
+func AUC(positives []bool, scores []float64) (float64, bool) {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return scores[order[a]] < scores[order[b]] })
+
+	n_positive, n_negative := 0, 0
+	rank_sum := 0.0
+	for start := 0; start < len(order); {
+		end := start
+		for end < len(order) && scores[order[end]] == scores[order[start]] {
+			end++
+		}
+		// Tied scores share the mean of their 1-based ranks
+		rank := float64(start+end+1) / 2
+		for _, i := range order[start:end] {
+			if positives[i] {
+				n_positive++
+				rank_sum += rank
+			} else {
+				n_negative++
+			}
+		}
+		start = end
+	}
+	if n_positive == 0 || n_negative == 0 {
+		return 0, false
+	}
+	p, n := float64(n_positive), float64(n_negative)
+	return (rank_sum - p*(p+1)/2) / (p * n), true
+}
+
+func ratio(numerator int, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
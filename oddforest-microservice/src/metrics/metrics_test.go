@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// near: compares scores computed in a different order than the reference, so allowing for rounding.
+func near(got float64, want float64) bool {
+	return math.Abs(got-want) < 1e-12
+}
+
+// Expected values are those of sklearn.metrics: accuracy_score, precision_recall_fscore_support (zero_division=0), roc_auc_score and confusion_matrix.
+// Binary fixture: six rows, half of them right, with tied vote fractions for "yes".
+var (
+	binaryLabels      = []string{"yes", "yes", "no", "no", "yes", "no"}
+	binaryPredictions = []string{"yes", "no", "yes", "no", "yes", "yes"}
+	binaryScores      = []map[string]float64{
+		{"yes": 0.8, "no": 0.2}, {"yes": 0.5, "no": 0.5}, {"yes": 0.5, "no": 0.5},
+		{"yes": 0.2, "no": 0.8}, {"yes": 0.5, "no": 0.5}, {"yes": 0.8, "no": 0.2},
+	}
+)
+
+// Multiclass fixture: classes a, b and c with supports 3, 2 and 1.
+var (
+	multiLabels      = []string{"a", "a", "a", "b", "b", "c"}
+	multiPredictions = []string{"a", "a", "b", "b", "c", "c"}
+	multiScores      = []map[string]float64{
+		{"a": 1}, {"a": 0.5, "b": 0.5}, {"b": 0.5, "c": 0.5},
+		{"b": 1}, {"b": 0.5, "c": 0.5}, {"c": 1},
+	}
+)
+
+func TestClassify(t *testing.T) {
+	auc := func(value float64) *float64 { return &value }
+	tests := []struct {
+		name        string
+		classes     []string
+		labels      []string
+		predictions []string
+		scores      []map[string]float64
+		average     string
+		want        Report
+	}{
+		{
+			name: "binary", labels: binaryLabels, predictions: binaryPredictions, scores: binaryScores, average: AverageBinary,
+			// roc_auc_score([1, 1, 0, 0, 1, 0], [.8, .5, .5, .2, .5, .8]) == 11/18: the tied scores count half
+			want: Report{Accuracy: 0.5, Precision: 0.5, Recall: 2.0 / 3, F1: 4.0 / 7, ROCAUC: auc(11.0 / 18), Average: AverageBinary, PositiveClass: "yes",
+				Classes: []string{"no", "yes"}, ConfusionMatrix: [][]int{{1, 2}, {1, 2}}},
+		},
+		{
+			name: "binary by default", labels: binaryLabels, predictions: binaryPredictions, scores: binaryScores,
+			want: Report{Accuracy: 0.5, Precision: 0.5, Recall: 2.0 / 3, F1: 4.0 / 7, ROCAUC: auc(11.0 / 18), Average: AverageBinary, PositiveClass: "yes",
+				Classes: []string{"no", "yes"}, ConfusionMatrix: [][]int{{1, 2}, {1, 2}}},
+		},
+		{
+			name: "binary macro", labels: binaryLabels, predictions: binaryPredictions, scores: binaryScores, average: AverageMacro,
+			want: Report{Accuracy: 0.5, Precision: 0.5, Recall: 0.5, F1: 17.0 / 35, ROCAUC: auc(11.0 / 18), Average: AverageMacro,
+				Classes: []string{"no", "yes"}, ConfusionMatrix: [][]int{{1, 2}, {1, 2}}},
+		},
+		{
+			name: "macro", labels: multiLabels, predictions: multiPredictions, scores: multiScores, average: AverageMacro,
+			// roc_auc_score(y, proba, multi_class="ovr", average="macro"): the mean of 5/6, 7/8 and 1
+			want: Report{Accuracy: 2.0 / 3, Precision: 2.0 / 3, Recall: 13.0 / 18, F1: 59.0 / 90, ROCAUC: auc(65.0 / 72), Average: AverageMacro,
+				Classes: []string{"a", "b", "c"}, ConfusionMatrix: [][]int{{2, 1, 0}, {0, 1, 1}, {0, 0, 1}}},
+		},
+		{
+			name: "binary falls back to macro", labels: multiLabels, predictions: multiPredictions, scores: multiScores, average: AverageBinary,
+			want: Report{Accuracy: 2.0 / 3, Precision: 2.0 / 3, Recall: 13.0 / 18, F1: 59.0 / 90, ROCAUC: auc(65.0 / 72), Average: AverageMacro,
+				Classes: []string{"a", "b", "c"}, ConfusionMatrix: [][]int{{2, 1, 0}, {0, 1, 1}, {0, 0, 1}}},
+		},
+		{
+			name: "weighted", labels: multiLabels, predictions: multiPredictions, scores: multiScores, average: AverageWeighted,
+			// roc_auc_score(y, proba, multi_class="ovr", average="weighted"): 5/6, 7/8 and 1 weighted 3:2:1
+			want: Report{Accuracy: 2.0 / 3, Precision: 0.75, Recall: 2.0 / 3, F1: 61.0 / 90, ROCAUC: auc(7.0 / 8), Average: AverageWeighted,
+				Classes: []string{"a", "b", "c"}, ConfusionMatrix: [][]int{{2, 1, 0}, {0, 1, 1}, {0, 0, 1}}},
+		},
+		{
+			// precision_recall_fscore_support(y, yhat, labels=["no", "yes"], pos_label="yes", average="binary", zero_division=0)
+			name: "model classes missing from the rows", classes: []string{"no", "yes"}, labels: []string{"no", "no"}, predictions: []string{"no", "no"},
+			scores: []map[string]float64{{"no": 1}, {"no": 1}},
+			want: Report{Accuracy: 1, Average: AverageBinary, PositiveClass: "yes",
+				Classes: []string{"no", "yes"}, ConfusionMatrix: [][]int{{2, 0}, {0, 0}}},
+		},
+		{
+			// precision_recall_fscore_support(y, yhat, labels=["a", "b", "c"], average="macro", zero_division=0)
+			name: "model classes decide the averaging", classes: []string{"a", "b", "c"}, labels: []string{"a", "b"}, predictions: []string{"a", "b"},
+			want: Report{Accuracy: 1, Precision: 2.0 / 3, Recall: 2.0 / 3, F1: 2.0 / 3, Average: AverageMacro,
+				Classes: []string{"a", "b", "c"}, ConfusionMatrix: [][]int{{1, 0, 0}, {0, 1, 0}, {0, 0, 0}}},
+		},
+		{
+			name: "labels the model never saw", classes: []string{"no", "yes"}, labels: []string{"maybe", "yes"}, predictions: []string{"yes", "yes"},
+			want: Report{Accuracy: 0.5, Precision: 1.0 / 6, Recall: 1.0 / 3, F1: 2.0 / 9, Average: AverageMacro,
+				Classes: []string{"maybe", "no", "yes"}, ConfusionMatrix: [][]int{{0, 0, 1}, {0, 0, 0}, {0, 0, 1}}},
+		},
+		{
+			name: "micro", labels: multiLabels, predictions: multiPredictions, average: AverageMicro,
+			want: Report{Accuracy: 2.0 / 3, Precision: 2.0 / 3, Recall: 2.0 / 3, F1: 2.0 / 3, Average: AverageMicro,
+				Classes: []string{"a", "b", "c"}, ConfusionMatrix: [][]int{{2, 1, 0}, {0, 1, 1}, {0, 0, 1}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Classify(test.classes, test.labels, test.predictions, test.scores, test.average)
+			want := test.want
+			for _, score := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"accuracy", got.Accuracy, want.Accuracy},
+				{"precision", got.Precision, want.Precision},
+				{"recall", got.Recall, want.Recall},
+				{"f1", got.F1, want.F1},
+			} {
+				if !near(score.got, score.want) {
+					t.Errorf("%s %v, want %v", score.name, score.got, score.want)
+				}
+			}
+			switch {
+			case (got.ROCAUC == nil) != (want.ROCAUC == nil):
+				t.Errorf("roc_auc %v, want %v", got.ROCAUC, want.ROCAUC)
+			case got.ROCAUC != nil && !near(*got.ROCAUC, *want.ROCAUC):
+				t.Errorf("roc_auc %v, want %v", *got.ROCAUC, *want.ROCAUC)
+			}
+			if got.Average != want.Average || got.PositiveClass != want.PositiveClass {
+				t.Errorf("average %q, positive class %q, want %q, %q", got.Average, got.PositiveClass, want.Average, want.PositiveClass)
+			}
+			if !slices.Equal(got.Classes, want.Classes) {
+				t.Errorf("classes %v, want %v", got.Classes, want.Classes)
+			}
+			if !slices.EqualFunc(got.ConfusionMatrix, want.ConfusionMatrix, slices.Equal) {
+				t.Errorf("confusion matrix %v, want %v", got.ConfusionMatrix, want.ConfusionMatrix)
+			}
+		})
+	}
+}
+
+func TestClassifyPerClass(t *testing.T) {
+	report := Classify(nil, multiLabels, multiPredictions, nil, AverageMacro)
+	// precision_recall_fscore_support(y, yhat, average=None)
+	want := map[string]ClassMetrics{
+		"a": {Precision: 1, Recall: 2.0 / 3, F1: 0.8, Support: 3},
+		"b": {Precision: 0.5, Recall: 0.5, F1: 0.5, Support: 2},
+		"c": {Precision: 0.5, Recall: 1, F1: 2.0 / 3, Support: 1},
+	}
+	for class, want := range want {
+		got := report.PerClass[class]
+		if !near(got.Precision, want.Precision) || !near(got.Recall, want.Recall) || !near(got.F1, want.F1) || got.Support != want.Support {
+			t.Errorf("class %s: %+v, want %+v", class, got, want)
+		}
+	}
+}
+
+func TestAUC(t *testing.T) {
+	tests := []struct {
+		name      string
+		positives []bool
+		scores    []float64
+		want      float64
+		ok        bool
+	}{
+		{"roc_auc_score documentation example", []bool{false, false, true, true}, []float64{0.1, 0.4, 0.35, 0.8}, 0.75, true},
+		{"separated", []bool{false, true}, []float64{0.2, 0.9}, 1, true},
+		{"inverted", []bool{true, false}, []float64{0.2, 0.9}, 0, true},
+		{"all tied", []bool{true, false, true, false}, []float64{0.5, 0.5, 0.5, 0.5}, 0.5, true},
+		// roc_auc_score([1, 1, 0, 0, 1, 0], [.8, .5, .5, .2, .5, .8]) == 11/18
+		{"tied ranks", []bool{true, true, false, false, true, false}, []float64{0.8, 0.5, 0.5, 0.2, 0.5, 0.8}, 11.0 / 18, true},
+		{"no negatives", []bool{true, true}, []float64{0.2, 0.9}, 0, false},
+		{"no positives", []bool{false, false}, []float64{0.2, 0.9}, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := AUC(test.positives, test.scores)
+			if ok != test.ok || !near(got, test.want) {
+				t.Errorf("AUC %v, %v, want %v, %v", got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestRegress(t *testing.T) {
+	// The mean_squared_error, mean_absolute_error and r2_score documentation example
+	got := Regress([]float64{3, -0.5, 2, 7}, []float64{2.5, 0, 2, 8})
+	if want := math.Sqrt(0.375); !near(got.RMSE, want) {
+		t.Errorf("rmse %v, want %v", got.RMSE, want)
+	}
+	if !near(got.MAE, 0.5) {
+		t.Errorf("mae %v, want 0.5", got.MAE)
+	}
+	if !near(got.R2, 0.9486081370449679) {
+		t.Errorf("r2 %v, want 0.9486081370449679", got.R2)
+	}
+}
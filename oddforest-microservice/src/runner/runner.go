@@ -0,0 +1,137 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	datafile "intel.com/oddforest-microservice/datafile"
+	metrics "intel.com/oddforest-microservice/metrics"
+)
+
+// Script is the location of the Python training and inference tool, relative to the server's working directory.
+var Script = "../../random_forest/main.py"
+
+// ResultsVersion is the version of the result envelope this server understands.
+const ResultsVersion = 3
+
+// Result: the JSON result envelope written by main.py. Only the section matching Task is populated; training a regression forest fills RegressionTraining instead of Training.
+type Result struct {
+	Version            int                         `json:"version"`
+	Task               string                      `json:"task"`
+	Status             string                      `json:"status"`
+	Error              *Error                      `json:"error,omitempty"`
+	Training           *metrics.Training           `json:"training,omitempty"`
+	RegressionTraining *metrics.RegressionTraining `json:"regression_training,omitempty"`
+	Inference          *Inference                  `json:"inference,omitempty"`
+	Trees              []string                    `json:"trees,omitempty"`
+	Schema             *datafile.Schema            `json:"schema,omitempty"`
+}
+
+// Inference: the outcome of an infer task. Classifiers are scored in Metrics and regression forests in RegressionMetrics; both are nil when the dataset has no label column to score against.
+type Inference struct {
+	Rows              int                 `json:"rows"`
+	Metrics           *metrics.Report     `json:"metrics"`
+	RegressionMetrics *metrics.Regression `json:"regression_metrics,omitempty"`
+}
+
+// Error: an error reported by the Python side. Type is the kind of failure (config, dataset, model, or a Python exception name).
+type Error struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func (self *Error) Error() string {
+	return self.Type + ": " + self.Message
+}
+
+// Output: everything the Python process wrote while running.
+type Output struct {
+	Stdout string
+	Stderr string
+}
+
+// Files written to a task's working directory by Run.
+const (
+	ResultsFile = "results.json"
+	StdoutFile  = "stdout.log"
+	StderrFile  = "stderr.log"
+)
+
+// CodeVersion: identifies the version of the Python tool by the SHA-256 of its source files, so a trained model can record exactly which code produced it.
+func CodeVersion() (string, error) {
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(Script), "*.py"))
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no Python sources found next to %s", Script)
+	}
+	sort.Strings(files)
+	hash := sha256.New()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		// Name and length first, so moving code between files changes the version too
+		fmt.Fprintf(hash, "%s\x00%d\x00", filepath.Base(file), len(data))
+		hash.Write(data)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Run: runs main.py against a config file and decodes its result envelope. The envelope and the process logs are kept in dir.
+// If the script reported a failure, the returned error is an *Error.
+func Run(ctx context.Context, configPath string, dir string) (Result, Output, error) {
+	var result Result
+	results_path := filepath.Join(dir, ResultsFile)
+	os.Remove(results_path)
+
+	cmd := exec.CommandContext(ctx, "python", Script, configPath, "--results", results_path)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	run_err := cmd.Run()
+	output := Output{Stdout: stdout.String(), Stderr: stderr.String()}
+	if err := os.WriteFile(filepath.Join(dir, StdoutFile), stdout.Bytes(), 0666); err != nil {
+		log.Print(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, StderrFile), stderr.Bytes(), 0666); err != nil {
+		log.Print(err)
+	}
+	if ctx.Err() != nil {
+		return result, output, ctx.Err()
+	}
+
+	data, err := os.ReadFile(results_path)
+	if err != nil || len(data) == 0 {
+		// No envelope at all: the interpreter itself failed before main.py could report anything
+		if run_err != nil {
+			return result, output, fmt.Errorf("random forest tool failed: %w", run_err)
+		}
+		return result, output, errors.New("random forest tool wrote no results")
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, output, fmt.Errorf("decoding results: %w", err)
+	}
+	if result.Version != ResultsVersion {
+		return result, output, fmt.Errorf("unsupported results version %d, expected %d", result.Version, ResultsVersion)
+	}
+	if result.Error != nil {
+		return result, output, result.Error
+	}
+	if run_err != nil {
+		return result, output, fmt.Errorf("random forest tool failed: %w", run_err)
+	}
+	return result, output, nil
+}
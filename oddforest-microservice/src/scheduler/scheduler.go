@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Job priorities. Higher priority jobs are started first; jobs of equal priority run in submission order.
+const (
+	PriorityLow    = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+// ErrQueueFull is returned by Submit when the queue is at capacity.
+var ErrQueueFull = errors.New("job queue is full")
+
+// Job: a unit of work run by one of the scheduler's workers. Jobs with an ID can be cancelled; a non-zero Timeout bounds how long Run may take.
+// Exactly one of Run and OnCancel is called: OnCancel, if set, when the job is cancelled while still queued, so anyone waiting on Run is released.
+type Job struct {
+	ID       string
+	Priority int
+	Timeout  time.Duration
+	Run      func(ctx context.Context)
+	OnCancel func()
+	seq      uint64
+}
+
+// Stats: a snapshot of the scheduler's load, reported through /status.
+type Stats struct {
+	Workers  int
+	Running  int
+	Queued   int
+	Capacity int
+}
+
+// Scheduler: a bounded worker pool fed by a priority queue.
+type Scheduler struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    jobQueue
+	workers  int
+	capacity int
+	running  int
+	seq      uint64
+	cancels  map[string]context.CancelFunc
+}
+
+// New: creates a scheduler running at most workers jobs at once, with room for capacity jobs waiting.
+func New(workers int, capacity int) *Scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	if capacity < 0 {
+		capacity = 0
+	}
+	s := &Scheduler{workers: workers, capacity: capacity, cancels: make(map[string]context.CancelFunc)}
+	s.cond = sync.NewCond(&s.mu)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+// Submit: queues a job, or returns ErrQueueFull if there is no room left. Idle workers count as room, so a job is only
+// turned away if it would have to wait for a worker and capacity jobs are already waiting.
+func (self *Scheduler) Submit(job Job) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	// Queued jobs idle workers have yet to pick up are not waiting on anyone
+	if len(self.queue) >= self.capacity+self.workers-self.running {
+		return ErrQueueFull
+	}
+	self.seq++
+	job.seq = self.seq
+	heap.Push(&self.queue, job)
+	self.cond.Signal()
+	return nil
+}
+
+// Cancel: removes a queued job, or cancels the context of a running one. found is false if no job with that ID is known.
+// A job removed from the queue (queued is true) never runs; its OnCancel is called instead, and the caller is responsible for recording that it was cancelled.
+func (self *Scheduler) Cancel(id string) (queued bool, found bool) {
+	self.mu.Lock()
+	for i, job := range self.queue {
+		if job.ID == id {
+			heap.Remove(&self.queue, i)
+			self.mu.Unlock()
+			if job.OnCancel != nil {
+				job.OnCancel()
+			}
+			return true, true
+		}
+	}
+	defer self.mu.Unlock()
+	if cancel, ok := self.cancels[id]; ok {
+		cancel()
+		return false, true
+	}
+	return false, false
+}
+
+// Stats: returns the current worker and queue usage.
+func (self *Scheduler) Stats() Stats {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return Stats{Workers: self.workers, Running: self.running, Queued: len(self.queue), Capacity: self.capacity}
+}
+
+// work: a worker loop, taking the next job off the queue and running it.
+func (self *Scheduler) work() {
+	for {
+		self.mu.Lock()
+		for len(self.queue) == 0 {
+			self.cond.Wait()
+		}
+		job := heap.Pop(&self.queue).(Job)
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if job.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), job.Timeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+		if job.ID != "" {
+			self.cancels[job.ID] = cancel
+		}
+		self.running++
+		self.mu.Unlock()
+
+		job.Run(ctx)
+
+		self.mu.Lock()
+		cancel()
+		if job.ID != "" {
+			delete(self.cancels, job.ID)
+		}
+		self.running--
+		self.mu.Unlock()
+	}
+}
+
+// jobQueue: a heap of jobs ordered by priority, then submission order.
+type jobQueue []Job
+
+func (q jobQueue) Len() int { return len(q) }
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].Priority != q[j].Priority {
+		return q[i].Priority > q[j].Priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q jobQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *jobQueue) Push(x any)   { *q = append(*q, x.(Job)) }
+func (q *jobQueue) Pop() any {
+	old := *q
+	n := len(old)
+	job := old[n-1]
+	*q = old[:n-1]
+	return job
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestPriorityOrder(t *testing.T) {
+	s := New(1, 4)
+	started, release := make(chan struct{}), make(chan struct{})
+	if err := s.Submit(Job{Run: func(ctx context.Context) {
+		close(started)
+		<-release
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	// The only worker is busy, so everything below waits in the queue
+	<-started
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	jobs := []struct {
+		name     string
+		priority int
+	}{
+		{"low", PriorityLow},
+		{"normal 1", PriorityNormal},
+		{"high", PriorityHigh},
+		{"normal 2", PriorityNormal},
+	}
+	for _, job := range jobs {
+		wg.Add(1)
+		err := s.Submit(Job{Priority: job.priority, Run: func(ctx context.Context) {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, job.name)
+		}})
+		if err != nil {
+			t.Fatalf("%s: %s", job.name, err)
+		}
+	}
+	if err := s.Submit(Job{Run: func(ctx context.Context) {}}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("job past capacity: %v, want %v", err, ErrQueueFull)
+	}
+	close(release)
+	wg.Wait()
+
+	// Higher priorities first, and jobs of equal priority in the order they were submitted
+	if want := []string{"high", "normal 1", "normal 2", "low"}; !slices.Equal(order, want) {
+		t.Errorf("run order %v, want %v", order, want)
+	}
+}
+
+func TestSubmitCountsIdleWorkers(t *testing.T) {
+	tests := []struct {
+		name     string
+		workers  int
+		capacity int
+		accepted int
+	}{
+		{"no waiting room", 2, 0, 2},
+		{"waiting room", 2, 3, 5},
+		{"single worker", 1, 0, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			release := make(chan struct{})
+			defer close(release)
+			s := New(test.workers, test.capacity)
+			for i := range test.accepted {
+				if err := s.Submit(Job{Run: func(ctx context.Context) { <-release }}); err != nil {
+					t.Fatalf("job %d: %s", i+1, err)
+				}
+			}
+			// Whether or not the workers have picked their jobs up yet, every worker is taken and the queue is full
+			if err := s.Submit(Job{Run: func(ctx context.Context) {}}); !errors.Is(err, ErrQueueFull) {
+				t.Errorf("job %d: %v, want %v", test.accepted+1, err, ErrQueueFull)
+			}
+		})
+	}
+}
+
+func TestCancelQueuedCallsOnCancel(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	s := New(1, 1)
+	s.Submit(Job{ID: "busy", Run: func(ctx context.Context) { <-release }})
+	// The worker may not have taken the first job yet, so the second is cancelled while still behind it
+	ran, cancelled := make(chan bool, 1), make(chan bool, 1)
+	if err := s.Submit(Job{ID: "waiting", Priority: PriorityLow, Run: func(ctx context.Context) { ran <- true }, OnCancel: func() { cancelled <- true }}); err != nil {
+		t.Fatal(err)
+	}
+	if queued, found := s.Cancel("waiting"); !queued || !found {
+		t.Fatalf("Cancel: queued %v, found %v, want true, true", queued, found)
+	}
+	select {
+	case <-cancelled:
+	default:
+		t.Error("OnCancel was not called")
+	}
+	select {
+	case <-ran:
+		t.Error("cancelled job ran")
+	default:
+	}
+}
@@ -0,0 +1,97 @@
+package session
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// ID prefixes, one per resource type. An ID is its prefix followed by the resource's ID_num, e.g. "m7".
+const (
+	ModelPrefix   = "m"
+	DatasetPrefix = "d"
+	ResultPrefix  = "r"
+	TaskPrefix    = "t"
+)
+
+// datasetVersionKey: the counter key of the versions of a dataset name.
+func datasetVersionKey(name string) string {
+	return "dataset_version:" + name
+}
+
+// IDAllocator: hands out numbers from monotonic counters, one per key: each resource type's prefix, and each dataset name's versions.
+// A counter only goes up and is persisted as it moves, so a number is never given out twice, even once what it named is deleted and the service restarts.
+// Safe for concurrent use. The zero value is ready to use and persists nothing.
+type IDAllocator struct {
+	mu       sync.Mutex
+	counters map[string]int
+	store    Store
+}
+
+// Next: moves a counter on and returns its new value. The first value of a counter is 1.
+func (self *IDAllocator) Next(key string) int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.counters == nil {
+		self.counters = make(map[string]int)
+	}
+	self.counters[key]++
+	self.save(key)
+	return self.counters[key]
+}
+
+// Observe: raises a counter to at least n, so numbers already in use, such as those in a catalogue written before counters were kept, are not given out.
+func (self *IDAllocator) Observe(key string, n int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.counters == nil {
+		self.counters = make(map[string]int)
+	}
+	if n > self.counters[key] {
+		self.counters[key] = n
+		self.save(key)
+	}
+}
+
+// restore: sets a counter read back from the store, keeping the higher value if it was already set.
+func (self *IDAllocator) restore(key string, n int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.counters == nil {
+		self.counters = make(map[string]int)
+	}
+	self.counters[key] = max(self.counters[key], n)
+}
+
+// save: persists a counter. Called with the lock held, so counters reach the store in the order they move.
+func (self *IDAllocator) save(key string) {
+	if self.store == nil {
+		return
+	}
+	if err := self.store.Put(CountersBucket, key, self.counters[key]); err != nil {
+		log.Printf("failed to persist %s/%s: %s", CountersBucket, key, err)
+	}
+}
+
+// nextID: gives out the next ID of a resource type.
+func (self *Session) nextID(prefix string) (int, string) {
+	id_num := self.ids.Next(prefix)
+	return id_num, prefix + fmt.Sprint(id_num)
+}
+
+// observeIDs: raises every resource type's counter past the IDs already catalogued.
+func (self *Session) observeIDs() {
+	for _, model := range self.models {
+		self.ids.Observe(ModelPrefix, model.ID_num)
+	}
+	for _, dataset := range self.datasets {
+		self.ids.Observe(DatasetPrefix, dataset.ID_num)
+		self.ids.Observe(datasetVersionKey(dataset.Name), dataset.Version)
+	}
+	for _, result := range self.results {
+		self.ids.Observe(ResultPrefix, result.ID_num)
+	}
+	for _, task := range self.tasks {
+		self.ids.Observe(TaskPrefix, task.ID_num)
+	}
+}
@@ -0,0 +1,360 @@
+package session
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Registry stages. A registered version starts in staging; at most one version of a registered model is in production at a time.
+const (
+	StageStaging    = "staging"
+	StageProduction = "production"
+	StageArchived   = "archived"
+)
+
+// Stages: every stage a registered version can be in.
+var Stages = []string{StageStaging, StageProduction, StageArchived}
+
+// RegisteredModel: a named model in the registry. Each version points at a catalogued model; aliases such as "champion" name a version.
+// Versions only go up: a removed version's number is never given out again. ProductionHistory lists the versions promoted to production,
+// oldest first, so a promotion can be rolled back.
+type RegisteredModel struct {
+	Name              string
+	LatestVersion     int
+	Versions          []ModelVersion
+	Aliases           map[string]int `json:",omitempty"`
+	ProductionHistory []int          `json:",omitempty"`
+	CreatedAt         time.Time
+}
+
+// ModelVersion: one version of a registered model.
+type ModelVersion struct {
+	Version        int
+	ModelID        string
+	Stage          string
+	CreatedAt      time.Time
+	StageChangedAt time.Time
+}
+
+// NotFoundError: a registered model, version, alias or model reference that does not exist.
+type NotFoundError struct {
+	Message string
+}
+
+func (self *NotFoundError) Error() string {
+	return self.Message
+}
+
+func notFound(format string, args ...any) error {
+	return &NotFoundError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Version: looks up a version by number.
+func (self *RegisteredModel) Version(version int) (*ModelVersion, bool) {
+	for i := range self.Versions {
+		if self.Versions[i].Version == version {
+			return &self.Versions[i], true
+		}
+	}
+	return nil, false
+}
+
+// Production: the version currently in production, if any.
+func (self *RegisteredModel) Production() (*ModelVersion, bool) {
+	for i := range self.Versions {
+		if self.Versions[i].Stage == StageProduction {
+			return &self.Versions[i], true
+		}
+	}
+	return nil, false
+}
+
+// setStage: moves a version to a stage. Promoting a version to production archives the version it replaces.
+func (self *RegisteredModel) setStage(version *ModelVersion, stage string, now time.Time) {
+	if stage == StageProduction {
+		if current, ok := self.Production(); ok && current != version {
+			current.Stage = StageArchived
+			current.StageChangedAt = now
+		}
+		if version.Stage != StageProduction {
+			self.ProductionHistory = append(self.ProductionHistory, version.Version)
+		}
+	}
+	version.Stage = stage
+	version.StageChangedAt = now
+}
+
+// ValidRegistryName: checks a registered model name. Names cannot hold "@", which separates a name from its stage, version or alias in a model reference.
+func ValidRegistryName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("registered model name cannot be empty")
+	}
+	if strings.ContainsAny(name, "@/") {
+		return fmt.Errorf("registered model name %q cannot contain '@' or '/'", name)
+	}
+	return nil
+}
+
+// validAlias: checks an alias name. Stage names and version numbers are already model reference selectors, so they cannot be aliases.
+func validAlias(alias string) error {
+	if err := ValidRegistryName(alias); err != nil {
+		return fmt.Errorf("invalid alias: %w", err)
+	}
+	if slices.Contains(Stages, alias) {
+		return fmt.Errorf("alias %q is a stage name", alias)
+	}
+	if _, err := parseVersion(alias); err == nil {
+		return fmt.Errorf("alias %q is a version number", alias)
+	}
+	return nil
+}
+
+// parseVersion: parses a version selector, "3" or "v3".
+func parseVersion(selector string) (int, error) {
+	return strconv.Atoi(strings.TrimPrefix(selector, "v"))
+}
+
+// saveRegisteredModel: persists a registered model, keyed by name.
+func (self *Session) saveRegisteredModel(registered RegisteredModel) {
+	self.persist(RegistryBucket, registered.Name, registered)
+}
+
+// Registry: returns every registered model.
+func (self *Session) Registry() []RegisteredModel {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return slices.Clone(self.registry)
+}
+
+// GetRegisteredModel: looks up a registered model by name.
+func (self *Session) GetRegisteredModel(name string) (RegisteredModel, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.getRegisteredModel(name)
+}
+
+func (self *Session) getRegisteredModel(name string) (RegisteredModel, bool) {
+	for _, registered := range self.registry {
+		if registered.Name == name {
+			return registered, true
+		}
+	}
+	return RegisteredModel{}, false
+}
+
+// updateRegisteredModel: applies an update to the named registered model and persists the result. Nothing is persisted if the update fails.
+// Called with the session locked. Copies handed out earlier share the versions, aliases and history, so the update works on fresh ones.
+func (self *Session) updateRegisteredModel(name string, update func(*RegisteredModel) error) (RegisteredModel, error) {
+	i := slices.IndexFunc(self.registry, func(registered RegisteredModel) bool { return registered.Name == name })
+	if i < 0 {
+		return RegisteredModel{}, notFound("registered model not found, name: %s", name)
+	}
+	registered := self.registry[i]
+	registered.Versions = slices.Clone(registered.Versions)
+	registered.ProductionHistory = slices.Clone(registered.ProductionHistory)
+	aliases := make(map[string]int, len(registered.Aliases))
+	for alias, version := range registered.Aliases {
+		aliases[alias] = version
+	}
+	registered.Aliases = aliases
+	if err := update(&registered); err != nil {
+		return self.registry[i], err
+	}
+	self.registry[i] = registered
+	self.saveRegisteredModel(registered)
+	return registered, nil
+}
+
+// RegisterModel: adds a catalogued model as the next version of the named registered model, in staging. The registered model is created on its first version.
+func (self *Session) RegisterModel(name string, model_id string) (RegisteredModel, error) {
+	if err := ValidRegistryName(name); err != nil {
+		return RegisteredModel{}, err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	model, ok := self.getModel(model_id)
+	if !ok {
+		return RegisteredModel{}, notFound("model not found, id: %s", model_id)
+	}
+	now := time.Now().UTC()
+	if _, ok := self.getRegisteredModel(name); !ok {
+		self.registry = append(self.registry, RegisteredModel{Name: name, CreatedAt: now})
+	}
+	return self.updateRegisteredModel(name, func(registered *RegisteredModel) error {
+		for _, version := range registered.Versions {
+			if version.ModelID == model_id {
+				return fmt.Errorf("model %s is already %s version %d", model_id, name, version.Version)
+			}
+			// Versions sharing a file, as models catalogued before each got a file of its own can, would serve the same forest
+			if other, ok := self.getModel(version.ModelID); ok && other.Path == model.Path {
+				return fmt.Errorf("model %s shares its file with %s version %d (model %s)", model_id, name, version.Version, other.ID)
+			}
+		}
+		registered.LatestVersion++
+		registered.Versions = append(registered.Versions, ModelVersion{Version: registered.LatestVersion, ModelID: model_id, Stage: StageStaging, CreatedAt: now, StageChangedAt: now})
+		return nil
+	})
+}
+
+// SetStage: moves a version of a registered model to a stage. Promoting a version to production archives the version it replaces.
+func (self *Session) SetStage(name string, version int, stage string) (RegisteredModel, error) {
+	if !slices.Contains(Stages, stage) {
+		return RegisteredModel{}, fmt.Errorf("unknown stage %q, must be one of %s", stage, strings.Join(Stages, ", "))
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.updateRegisteredModel(name, func(registered *RegisteredModel) error {
+		target, ok := registered.Version(version)
+		if !ok {
+			return notFound("version not found, %s version %d", name, version)
+		}
+		registered.setStage(target, stage, time.Now().UTC())
+		return nil
+	})
+}
+
+// Rollback: returns a registered model to the version that was in production before the current one. The current production version is archived.
+// Versions that have since been removed are skipped. Rolling back again goes further back through the production history.
+func (self *Session) Rollback(name string) (RegisteredModel, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.updateRegisteredModel(name, func(registered *RegisteredModel) error {
+		current, ok := registered.Production()
+		if !ok {
+			return fmt.Errorf("no version of %s is in production", name)
+		}
+		history := registered.ProductionHistory
+		if n := len(history); n != 0 && history[n-1] == current.Version {
+			history = history[:n-1]
+		}
+		for len(history) != 0 {
+			previous, ok := registered.Version(history[len(history)-1])
+			if ok && previous != current {
+				now := time.Now().UTC()
+				current.Stage = StageArchived
+				current.StageChangedAt = now
+				previous.Stage = StageProduction
+				previous.StageChangedAt = now
+				registered.ProductionHistory = history
+				return nil
+			}
+			history = history[:len(history)-1]
+		}
+		return fmt.Errorf("%s has no earlier production version to roll back to", name)
+	})
+}
+
+// SetAlias: points an alias of a registered model at one of its versions, moving the alias if it already exists.
+func (self *Session) SetAlias(name string, alias string, version int) (RegisteredModel, error) {
+	if err := validAlias(alias); err != nil {
+		return RegisteredModel{}, err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.updateRegisteredModel(name, func(registered *RegisteredModel) error {
+		if _, ok := registered.Version(version); !ok {
+			return notFound("version not found, %s version %d", name, version)
+		}
+		registered.Aliases[alias] = version
+		return nil
+	})
+}
+
+// RemoveAlias: removes an alias of a registered model.
+func (self *Session) RemoveAlias(name string, alias string) (RegisteredModel, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.updateRegisteredModel(name, func(registered *RegisteredModel) error {
+		if _, ok := registered.Aliases[alias]; !ok {
+			return notFound("alias not found, %s@%s", name, alias)
+		}
+		delete(registered.Aliases, alias)
+		return nil
+	})
+}
+
+// UnregisterModel: removes every registry version pointing at a model, along with their aliases and production history, before the model is deleted.
+// A model that is in production is left registered, and an error returned, so production is never left pointing at nothing.
+func (self *Session) UnregisterModel(model_id string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, registered := range self.registry {
+		for _, version := range registered.Versions {
+			if version.ModelID == model_id && version.Stage == StageProduction {
+				return fmt.Errorf("model %s is %s version %d in production; promote another version or roll back first", model_id, registered.Name, version.Version)
+			}
+		}
+	}
+	for _, registered := range self.registry {
+		if !slices.ContainsFunc(registered.Versions, func(version ModelVersion) bool { return version.ModelID == model_id }) {
+			continue
+		}
+		self.updateRegisteredModel(registered.Name, func(registered *RegisteredModel) error {
+			for _, version := range registered.Versions {
+				if version.ModelID != model_id {
+					continue
+				}
+				for alias, aliased := range registered.Aliases {
+					if aliased == version.Version {
+						delete(registered.Aliases, alias)
+					}
+				}
+				registered.ProductionHistory = slices.DeleteFunc(registered.ProductionHistory, func(promoted int) bool { return promoted == version.Version })
+			}
+			registered.Versions = slices.DeleteFunc(registered.Versions, func(version ModelVersion) bool { return version.ModelID == model_id })
+			return nil
+		})
+	}
+	return nil
+}
+
+// ResolveModel: looks up a model by reference. A reference is either a model ID such as "m7", or a registered model name and a selector separated by "@":
+// a stage ("churn@production"; the newest version in the stage), a version number ("churn@3" or "churn@v3"), or an alias ("churn@champion").
+func (self *Session) ResolveModel(ref string) (Model, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	name, selector, registered_ref := strings.Cut(ref, "@")
+	if !registered_ref {
+		model, ok := self.getModel(ref)
+		if !ok {
+			return Model{}, notFound("model not found, id: %s", ref)
+		}
+		return model, nil
+	}
+	registered, ok := self.getRegisteredModel(name)
+	if !ok {
+		return Model{}, notFound("registered model not found, name: %s", name)
+	}
+	var version *ModelVersion
+	switch {
+	case slices.Contains(Stages, selector):
+		for i := range registered.Versions {
+			if registered.Versions[i].Stage == selector {
+				version = &registered.Versions[i]
+			}
+		}
+		if version == nil {
+			return Model{}, notFound("no version of %s is in %s", name, selector)
+		}
+	default:
+		number, err := parseVersion(selector)
+		if err != nil {
+			aliased, ok := registered.Aliases[selector]
+			if !ok {
+				return Model{}, notFound("alias not found, %s", ref)
+			}
+			number = aliased
+		}
+		if version, ok = registered.Version(number); !ok {
+			return Model{}, notFound("version not found, %s version %d", name, number)
+		}
+	}
+	model, ok := self.getModel(version.ModelID)
+	if !ok {
+		return Model{}, notFound("model not found, id: %s (%s)", version.ModelID, ref)
+	}
+	return model, nil
+}
@@ -4,14 +4,38 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	datafile "intel.com/oddforest-microservice/datafile"
+	forest "intel.com/oddforest-microservice/forest"
+	metrics "intel.com/oddforest-microservice/metrics"
 )
 
 // Internal data types to hold session, model, dataset, result, and task data during runtime.
+// Session is safe for concurrent use: the catalogue is only read and changed through its methods, which take its lock and hand out copies.
+// Changes are written through to the store while the lock is held, so the store sees them in the order they were made.
 type Session struct {
+	mu       sync.RWMutex
+	models   []Model
+	datasets []Dataset
+	results  []Result
+	tasks    []Task
+	registry []RegisteredModel
+	store    Store
+	ids      IDAllocator
+}
+
+// Catalogue: a copy of everything in a session.
+type Catalogue struct {
 	Models   []Model
 	Datasets []Dataset
 	Results  []Result
 	Tasks    []Task
+	Registry []RegisteredModel
 }
 
 type Model struct {
@@ -22,37 +46,93 @@ type Model struct {
 	ID_num            int
 	Path              string
 	InferName         string
-	UnoptValAccuracy  float64
-	UnoptValRecall    float64
-	UnoptTestAccuracy float64
-	UnoptTestRecall   float64
-	ValAccuracy       float64
-	ValRecall         float64
-	TestAccuracy      float64
-	TestRecall        float64
+	ModelType         string
+	Classes           []string
+	Average           string
+	Metrics           *metrics.Training
+	RegressionMetrics *metrics.RegressionTraining
+	CreatedAt         time.Time
+	ForestPath        string
+	Hyperparameters   *forest.Hyperparameters
+	Lineage           *Lineage
 }
 
+// Lineage: what a trained model was made from. The model is pinned to the exact dataset version and file it was trained on, and records the training request,
+// the version of the training code (see runner.CodeVersion), and the model it replaced, if one with the same name existed. Models uploaded rather than trained have none.
+type Lineage struct {
+	DatasetID      string
+	DatasetName    string
+	DatasetVersion int
+	DatasetSHA256  string
+	Config         TrainingConfig
+	CodeVersion    string
+	ParentModelID  string `json:",omitempty"`
+}
+
+// Dataset: an uploaded dataset file. Format is one of the datafile formats; datasets catalogued before formats were recorded are CSV.
+// Files are stored under their SHA-256, which is also the dataset's SHA256; datasets catalogued before then get theirs when they are first verified.
+// Each upload of a new file under the same Name is the next Version of that dataset. A version's file never changes once it is ready.
+// Datasets sent in chunks are DatasetUploading, with their progress in Upload and no Path, until the upload is finalized.
 type Dataset struct {
 	Name       string
+	Version    int
 	ID         string
 	Datapoints int
 	ID_num     int
 	Path       string
+	Format     string
+	SHA256     string
+	Status     string
+	Upload     *UploadProgress `json:",omitempty"`
+	CreatedAt  time.Time
+	Schema     *datafile.Schema
+}
+
+// Dataset statuses. Datasets catalogued before chunked uploads existed have no status and are ready.
+const (
+	DatasetUploading = "uploading"
+	DatasetReady     = "ready"
+)
+
+// UploadProgress: how far a chunked upload has got. TotalBytes is the size declared when the upload was initiated, or 0 if none was.
+// Chunks are written to PartPath until the upload is finalized.
+type UploadProgress struct {
+	Filename      string
+	ReceivedBytes int64
+	TotalBytes    int64
+	PartPath      string
+	UpdatedAt     time.Time
 }
 
 type Result struct {
-	ModelID   string
-	DatasetID string
-	Tree      string
-	Precision string
-	Recall    string
+	ID                string
+	ID_num            int
+	ModelID           string
+	DatasetID         string
+	Tree              string
+	CreatedAt         time.Time
+	TaskID            string
+	Rows              int
+	Metrics           *metrics.Report
+	RegressionMetrics *metrics.Regression
+	ArtifactPath      string
+	PredictionsPath   string
 }
 
 type Task struct {
-	ID      string
-	ModelID string
-	Status  string
-	ID_num  int
+	ID         string
+	ModelID    string
+	ResultID   string
+	Status     string
+	ID_num     int
+	Type       string
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+	Stdout     string
+	Stderr     string
+	Error      string
+	WorkDir    string
 }
 
 // Validation structures to capture our incoming TOML data
@@ -67,17 +147,49 @@ type TrainingConfig struct {
 	FeaturesFraction float64  `toml:"x_features_fraction"`
 	DataSplit        float64  `toml:"data_split"`
 	ShowUnoptimzied  bool     `toml:"show_unoptimized"`
+	Priority         int      `toml:"priority"`
+	TimeoutSeconds   int      `toml:"timeout_seconds"`
+	Average          string   `toml:"average"`
+	ModelType        string   `toml:"model_type"`
 }
 
 type UploadConfig struct {
 	Name string `form:"name" toml:"name" binding:"required"`
 }
 
+// ChunkedUploadConfig: starts a chunked upload of a dataset file. Size is optional; when given, chunks past it are refused and finalizing needs every byte.
+type ChunkedUploadConfig struct {
+	Filename string `toml:"filename" binding:"required"`
+	Size     int64  `toml:"size"`
+}
+
+// FinalizeUploadConfig: completes a chunked upload. SHA256 is the hex SHA-256 of the whole file, checked against what was received.
+type FinalizeUploadConfig struct {
+	SHA256 string `toml:"sha256" binding:"required"`
+}
+
+// InferConfig: ModelID is a model ID or a registry reference such as "churn@production" (see Session.ResolveModel).
 type InferConfig struct {
 	ModelID   string `form:"modelid" toml:"modelid" binding:"required"`
 	DatasetID string `form:"datasetid" toml:"datasetid" binding:"required"`
 }
 
+// RegisterModelConfig: adds a model as the next version of a registered model.
+type RegisterModelConfig struct {
+	ModelID string `toml:"model_id" binding:"required"`
+}
+
+// PromoteConfig: moves a registered version to a stage, production if none is given.
+type PromoteConfig struct {
+	Version int    `toml:"version" binding:"required"`
+	Stage   string `toml:"stage"`
+}
+
+// AliasConfig: points an alias at a registered version.
+type AliasConfig struct {
+	Version int `toml:"version" binding:"required"`
+}
+
 type DownloadConfig struct {
 	ModelID string `form:"modelid" toml:"modelid" binding:"required"`
 }
@@ -85,30 +197,118 @@ type DownloadConfig struct {
 // Structs for our structured responses to the client
 type TrainingResponse struct {
 	Response string
+	TaskID   string
+}
+
+type TaskResponse struct {
+	Response string
+	TaskID   string
 }
 
 type InferenceResponse struct {
-	TrainedPrecision float64
-	TrainedRecall    float64
+	ResultID          string
+	Metrics           *metrics.Report
+	RegressionMetrics *metrics.Regression
+}
+
+// RowPrediction: the prediction for one row of a predict request. Classifiers fill in the class and votes, regression forests the value.
+type RowPrediction struct {
+	Row        int
+	Class      string         `json:",omitempty"`
+	Votes      map[string]int `json:",omitempty"`
+	TreeVotes  []string       `json:",omitempty"`
+	Value      *float64       `json:",omitempty"`
+	TreeValues []float64      `json:",omitempty"`
+}
+
+type PredictResponse struct {
+	ModelID     string
+	Predictions []RowPrediction
+}
+
+// ValidationProblem: one problem found in a request. Row is 1-based, and 0 for problems not tied to a row.
+type ValidationProblem struct {
+	Row     int `json:",omitempty"`
+	Field   string
+	Message string
+}
+
+type ValidationResponse struct {
+	Error    string
+	Problems []ValidationProblem
+}
+
+// LineageResponse: a model's lineage. DatasetAvailable is true while the dataset version it was trained on is still catalogued with the same file.
+type LineageResponse struct {
+	ModelID          string
+	Lineage          *Lineage
+	DatasetAvailable bool
+}
+
+// ModelDetails: a model along with the size of its file and the dataset it was trained on, if still known
+type ModelDetails struct {
+	Model
+	SizeBytes     int64
+	SourceDataset *Dataset
+}
+
+// DatasetDetails: a dataset along with the size of its file
+type DatasetDetails struct {
+	Dataset
+	SizeBytes int64
 }
 
 // Struct for holding toml info to submit to the training script
 type RandomForestTrainingConfig struct {
 	TaskType         string   `toml:"task"`
 	FilePath         string   `toml:"input_data"`
+	InputFormat      string   `toml:"input_format,omitempty"`
 	Features         []string `toml:"features"`
 	InferenceName    string   `toml:"y_axis"`
 	ModelPath        string   `toml:"path"`
+	ExportPath       string   `toml:"export_path,omitempty"`
+	PredictionsPath  string   `toml:"predictions_path,omitempty"`
 	NTrees           int      `toml:"n_trees"`
 	SampleSplit      int      `toml:"min_samples_split"`
 	MaxDepth         int      `toml:"max_depth"`
 	FeaturesFraction float64  `toml:"x_features_fraction"`
 	DataSplit        float64  `toml:"data_split"`
 	ShowUnoptimzied  bool     `toml:"show_unoptimized"`
+	Average          string   `toml:"average,omitempty"`
+	ModelType        string   `toml:"model_type,omitempty"`
 }
 
 func (self *Session) Setup(volumePath string) {
-	// Check along the volumePath for any existing models, datasets
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	// Open the catalogue store and load everything the API was showing before the restart
+	store, err := OpenBoltStore(catalogueStorePath(volumePath))
+	if err != nil {
+		log.Printf("catalogue store not available, session will not persist: %s", err)
+		self.store = MemoryStore{}
+	} else {
+		self.store = store
+	}
+	self.ids.store = self.store
+	if err := self.store.Load(self); err != nil {
+		log.Print(err)
+	}
+	// Catalogues written before the counters were kept only have their IDs to go by
+	self.observeIDs()
+	// Any task still in flight when the service stopped will never finish. Training tasks may have left a partly written model,
+	// which must go before the scan below adopts it as an uploaded one
+	for i := range self.tasks {
+		if !self.tasks[i].Finished() {
+			if self.tasks[i].Type == "train" {
+				self.removeTrainingOutputs(TrainedModelPath(filepath.Join(volumePath, "models"), self.tasks[i].ID))
+			}
+			self.tasks[i].Error = "interrupted by service restart"
+			self.tasks[i].Transition(TaskFailed)
+			self.saveTask(self.tasks[i])
+		}
+	}
+
+	// Check along the volumePath for any existing models, datasets not yet in the catalogue
 	models_exists := false
 	datasets_exists := false
 	default_feature := []string{"Unknown"}
@@ -119,27 +319,55 @@ func (self *Session) Setup(volumePath string) {
 		for _, file := range files {
 			if file.Name() == "models" {
 				models_exists = true
-				models, err := os.ReadDir("models")
+				models, err := os.ReadDir(filepath.Join(volumePath, "models"))
 				if err != nil {
 					log.Print(err)
 				}
-				for i, model := range models {
-					self.Models = append(self.Models, Model{model.Name(), "m" + fmt.Sprint(i), "Unknown", default_feature, i, volumePath + "models" + model.Name() + ".model", "Unknown", 0, 0, 0, 0, 0, 0, 0, 0})
+				for _, model := range models {
+					if strings.HasSuffix(model.Name(), forest.Extension) || strings.HasSuffix(model.Name(), PartialSuffix) {
+						continue
+					}
+					path := filepath.Join(volumePath, "models", model.Name())
+					if slices.ContainsFunc(self.models, func(m Model) bool { return m.Path == path }) {
+						continue
+					}
+					new_model := Model{Name: model.Name(), TrainedDataset: "Unknown", Features: default_feature, Path: path, InferName: "Unknown", CreatedAt: modTime(model)}
+					if rf, err := forest.Load(forest.PathFor(path)); err == nil {
+						new_model.ApplyForest(rf, forest.PathFor(path))
+					}
+					self.addModel(new_model)
 				}
 			}
 			if file.Name() == "datasets" {
 				datasets_exists = true
-				datasets, err := os.ReadDir("datasets")
+				datasets, err := os.ReadDir(filepath.Join(volumePath, "datasets"))
 				if err != nil {
 					log.Print(err)
 				}
-				for i, dataset := range datasets {
-					self.Datasets = append(self.Datasets, Dataset{dataset.Name(), "d" + fmt.Sprint(i), 0, i, volumePath + "datasets" + dataset.Name() + ".csv"})
+				for _, dataset := range datasets {
+					path := filepath.Join(volumePath, "datasets", dataset.Name())
+					if slices.ContainsFunc(self.datasets, func(d Dataset) bool { return d.Path == path }) {
+						continue
+					}
+					format, err := datafile.Detect(path)
+					if err != nil {
+						log.Print(err)
+						continue
+					}
+					self.addDataset(Dataset{Name: datafile.TrimExtension(dataset.Name()), Path: path, Format: format, CreatedAt: modTime(dataset)})
 				}
 			}
 		}
 	}
 
+	// Datasets catalogued before versioning, or just found on the volume, become the next version of their name, oldest first
+	for i := range self.datasets {
+		if self.datasets[i].Version == 0 && self.datasets[i].Ready() {
+			self.datasets[i].Version = self.NextDatasetVersion(self.datasets[i].Name)
+			self.saveDataset(self.datasets[i])
+		}
+	}
+
 	if !models_exists {
 		err := os.Mkdir(volumePath+"/models", 0777)
 		if err != nil && !os.IsExist(err) {
@@ -154,3 +382,440 @@ func (self *Session) Setup(volumePath string) {
 		}
 	}
 }
+
+// Write-through helpers: every change to the catalogue is persisted to the store as it happens.
+func (self *Session) saveModel(model Model) {
+	self.persist(ModelsBucket, model.ID, model)
+}
+
+func (self *Session) saveDataset(dataset Dataset) {
+	self.persist(DatasetsBucket, dataset.ID, dataset)
+}
+
+func (self *Session) saveResult(result Result) {
+	self.persist(ResultsBucket, result.ID, result)
+}
+
+func (self *Session) saveTask(task Task) {
+	self.persist(TasksBucket, task.ID, task)
+}
+
+func (self *Session) persist(bucket string, id string, value any) {
+	if self.store == nil {
+		return
+	}
+	if err := self.store.Put(bucket, id, value); err != nil {
+		log.Printf("failed to persist %s/%s: %s", bucket, id, err)
+	}
+}
+
+func (self *Session) unpersist(bucket string, id string) {
+	if self.store == nil {
+		return
+	}
+	if err := self.store.Delete(bucket, id); err != nil {
+		log.Printf("failed to delete %s/%s: %s", bucket, id, err)
+	}
+}
+
+// Close: flushes and closes the catalogue store.
+func (self *Session) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.store == nil {
+		return nil
+	}
+	return self.store.Close()
+}
+
+// ApplyForest: fills in a model's metadata from its portable export.
+func (self *Model) ApplyForest(rf *forest.Forest, forest_path string) {
+	self.ForestPath = forest_path
+	self.Features = rf.Features
+	if rf.InferName != "" {
+		self.InferName = rf.InferName
+	}
+	self.ModelType = rf.ModelType
+	if self.ModelType == "" {
+		self.ModelType = forest.Classification
+	}
+	if len(rf.Classes) != 0 {
+		self.Classes = rf.Classes
+	}
+	hyperparameters := rf.Hyperparameters
+	self.Hyperparameters = &hyperparameters
+}
+
+// Models: returns every model, oldest first.
+func (self *Session) Models() []Model {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return slices.Clone(self.models)
+}
+
+// Datasets: returns every dataset, oldest first.
+func (self *Session) Datasets() []Dataset {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return slices.Clone(self.datasets)
+}
+
+// Results: returns every result, oldest first.
+func (self *Session) Results() []Result {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return slices.Clone(self.results)
+}
+
+// Tasks: returns every task, oldest first.
+func (self *Session) Tasks() []Task {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return slices.Clone(self.tasks)
+}
+
+// Catalogue: returns a copy of everything in the session, taken at one point in time.
+func (self *Session) Catalogue() Catalogue {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return Catalogue{
+		Models:   slices.Clone(self.models),
+		Datasets: slices.Clone(self.datasets),
+		Results:  slices.Clone(self.results),
+		Tasks:    slices.Clone(self.tasks),
+		Registry: slices.Clone(self.registry),
+	}
+}
+
+// GetModel: looks up a model by ID.
+func (self *Session) GetModel(id string) (Model, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.getModel(id)
+}
+
+func (self *Session) getModel(id string) (Model, bool) {
+	for _, model := range self.models {
+		if model.ID == id {
+			return model, true
+		}
+	}
+	return Model{}, false
+}
+
+// GetDataset: looks up a dataset by ID.
+func (self *Session) GetDataset(id string) (Dataset, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	for _, dataset := range self.datasets {
+		if dataset.ID == id {
+			return dataset, true
+		}
+	}
+	return Dataset{}, false
+}
+
+// DatasetVersions: returns every version of the named dataset, oldest first.
+func (self *Session) DatasetVersions(name string) []Dataset {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	datasets := []Dataset{}
+	for _, dataset := range self.datasets {
+		if dataset.Name == name {
+			datasets = append(datasets, dataset)
+		}
+	}
+	slices.SortStableFunc(datasets, func(a, b Dataset) int { return a.Version - b.Version })
+	return datasets
+}
+
+// NextDatasetVersion: gives out the next version of the named dataset. Versions only go up: a deleted version's number is never given out again.
+func (self *Session) NextDatasetVersion(name string) int {
+	return self.ids.Next(datasetVersionKey(name))
+}
+
+// FindDatasetByHash: looks up a complete dataset by the SHA-256 of its file.
+func (self *Session) FindDatasetByHash(sha256 string) (Dataset, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.findDatasetByHash(sha256)
+}
+
+func (self *Session) findDatasetByHash(sha256 string) (Dataset, bool) {
+	for _, dataset := range self.datasets {
+		if dataset.SHA256 == sha256 && dataset.Ready() {
+			return dataset, true
+		}
+	}
+	return Dataset{}, false
+}
+
+// DatasetFileShared: reports whether a dataset other than the given one is catalogued with the SHA-256 a dataset file is stored under.
+// Uploads of the same file are stored at the same path, so one that fails must leave the file to the others.
+func (self *Session) DatasetFileShared(sha256 string, dataset_id string) bool {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return slices.ContainsFunc(self.datasets, func(dataset Dataset) bool {
+		return dataset.ID != dataset_id && dataset.SHA256 == sha256
+	})
+}
+
+// UpdateDataset: applies an update to the dataset with the given ID and persists the result.
+// The update runs with the session locked, so it must not call back into the session.
+func (self *Session) UpdateDataset(id string, update func(*Dataset) error) (Dataset, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for i := range self.datasets {
+		if self.datasets[i].ID == id {
+			// Copies handed out earlier share the upload progress, so change a fresh one
+			dataset := self.datasets[i]
+			if dataset.Upload != nil {
+				progress := *dataset.Upload
+				dataset.Upload = &progress
+			}
+			if err := update(&dataset); err != nil {
+				return self.datasets[i], err
+			}
+			self.datasets[i] = dataset
+			self.saveDataset(dataset)
+			return dataset, nil
+		}
+	}
+	return Dataset{}, fmt.Errorf("dataset not found, id: %s", id)
+}
+
+// AddReadyDataset: adds a complete dataset as the next version of its name, unless a ready dataset with the same SHA256 is already catalogued,
+// in which case that one is returned along with false. Checking and adding under one lock means two uploads of the same file are never both catalogued.
+func (self *Session) AddReadyDataset(dataset Dataset) (Dataset, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if existing, ok := self.findDatasetByHash(dataset.SHA256); ok {
+		return existing, false
+	}
+	dataset.Status = DatasetReady
+	dataset.Version = self.NextDatasetVersion(dataset.Name)
+	return self.addDataset(dataset), true
+}
+
+// FinishUpload: applies the update that completes an uploading dataset and makes it ready, as the next version of its name.
+// If a ready dataset with the same SHA256 as the updated one is already catalogued, the uploading dataset is removed instead and the existing one returned along with false.
+// The update runs with the session locked, so it must not call back into the session.
+func (self *Session) FinishUpload(id string, update func(*Dataset) error) (Dataset, bool, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	i := slices.IndexFunc(self.datasets, func(dataset Dataset) bool { return dataset.ID == id })
+	if i < 0 {
+		return Dataset{}, false, fmt.Errorf("dataset not found, id: %s", id)
+	}
+	dataset := self.datasets[i]
+	dataset.Upload = nil
+	if err := update(&dataset); err != nil {
+		return self.datasets[i], false, err
+	}
+	if existing, ok := self.findDatasetByHash(dataset.SHA256); ok {
+		self.datasets = slices.Delete(self.datasets, i, i+1)
+		self.unpersist(DatasetsBucket, id)
+		return existing, false, nil
+	}
+	dataset.Status = DatasetReady
+	dataset.Version = self.NextDatasetVersion(dataset.Name)
+	self.datasets[i] = dataset
+	self.saveDataset(dataset)
+	return dataset, true, nil
+}
+
+// Ready: reports whether a dataset's file is complete and can be used.
+func (self Dataset) Ready() bool {
+	return self.Status == "" || self.Status == DatasetReady
+}
+
+// ApplySchema: records a dataset's profile, and its row count as Datapoints.
+func (self *Dataset) ApplySchema(schema *datafile.Schema) {
+	self.Schema = schema
+	self.Datapoints = schema.Rows
+}
+
+// GetResult: looks up a result by ID.
+func (self *Session) GetResult(id string) (Result, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	for _, result := range self.results {
+		if result.ID == id {
+			return result, true
+		}
+	}
+	return Result{}, false
+}
+
+// FindResults: returns the results produced with a model and on a dataset, oldest first. An empty ID matches any model or dataset.
+func (self *Session) FindResults(model_id string, dataset_id string) []Result {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	results := []Result{}
+	for _, result := range self.results {
+		if (model_id == "" || result.ModelID == model_id) && (dataset_id == "" || result.DatasetID == dataset_id) {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// AddResult: assigns a new result its ID, registers it, and returns it.
+func (self *Session) AddResult(result Result) Result {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result.ID_num, result.ID = self.nextID(ResultPrefix)
+	self.results = append(self.results, result)
+	self.saveResult(result)
+	return result
+}
+
+// AddModel: assigns a new model its ID, registers it, and returns it.
+func (self *Session) AddModel(model Model) Model {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.addModel(model)
+}
+
+func (self *Session) addModel(model Model) Model {
+	model.ID_num, model.ID = self.nextID(ModelPrefix)
+	self.models = append(self.models, model)
+	self.saveModel(model)
+	return model
+}
+
+// AddDataset: assigns a new dataset its ID, registers it, and returns it.
+func (self *Session) AddDataset(dataset Dataset) Dataset {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.addDataset(dataset)
+}
+
+func (self *Session) addDataset(dataset Dataset) Dataset {
+	dataset.ID_num, dataset.ID = self.nextID(DatasetPrefix)
+	self.datasets = append(self.datasets, dataset)
+	self.saveDataset(dataset)
+	return dataset
+}
+
+// RemoveModel: removes a model from the catalogue. Returns false if the ID is unknown.
+func (self *Session) RemoveModel(id string) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	i := slices.IndexFunc(self.models, func(model Model) bool { return model.ID == id })
+	if i < 0 {
+		return false
+	}
+	self.models = slices.Delete(self.models, i, i+1)
+	self.unpersist(ModelsBucket, id)
+	return true
+}
+
+// RemoveDataset: removes a dataset from the catalogue. Returns false if the ID is unknown.
+func (self *Session) RemoveDataset(id string) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	i := slices.IndexFunc(self.datasets, func(dataset Dataset) bool { return dataset.ID == id })
+	if i < 0 {
+		return false
+	}
+	self.datasets = slices.Delete(self.datasets, i, i+1)
+	self.unpersist(DatasetsBucket, id)
+	return true
+}
+
+// RemoveResult: removes a result from the catalogue. Returns false if the ID is unknown.
+func (self *Session) RemoveResult(id string) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	i := slices.IndexFunc(self.results, func(result Result) bool { return result.ID == id })
+	if i < 0 {
+		return false
+	}
+	self.results = slices.Delete(self.results, i, i+1)
+	self.unpersist(ResultsBucket, id)
+	return true
+}
+
+// RemoveTask: removes a task from the catalogue. Returns false if the ID is unknown.
+func (self *Session) RemoveTask(id string) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	i := slices.IndexFunc(self.tasks, func(task Task) bool { return task.ID == id })
+	if i < 0 {
+		return false
+	}
+	self.tasks = slices.Delete(self.tasks, i, i+1)
+	self.unpersist(TasksBucket, id)
+	return true
+}
+
+// LatestModelNamed: looks up the most recently added model with the given name.
+func (self *Session) LatestModelNamed(name string) (Model, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	for i := len(self.models) - 1; i >= 0; i-- {
+		if self.models[i].Name == name {
+			return self.models[i], true
+		}
+	}
+	return Model{}, false
+}
+
+// ModelFileShared: reports whether a model other than the given one uses a file, as its model file or its portable export.
+// Models catalogued before every model got a file of its own can share one.
+func (self *Session) ModelFileShared(path string, model_id string) bool {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return slices.ContainsFunc(self.models, func(model Model) bool {
+		return model.ID != model_id && (model.Path == path || model.ForestPath == path)
+	})
+}
+
+// ModelsTrainedOn: returns every model that records the given dataset as its training data.
+func (self *Session) ModelsTrainedOn(dataset_id string) []Model {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	var models []Model
+	for _, model := range self.models {
+		if model.TrainedDataset == dataset_id {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// modTime: the modification time of a directory entry, used as the creation time of files found on the volume.
+func modTime(entry os.DirEntry) time.Time {
+	info, err := entry.Info()
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime().UTC()
+}
+
+// PartialSuffix: marks the model and export files the training tool is still writing. It renames them into place once the model has been scored.
+const PartialSuffix = ".partial"
+
+// TrainedModelPath: the file a training task saves its model to. It is named after the task, so retraining a name never overwrites the file of the model it replaces.
+func TrainedModelPath(models_root string, task_id string) string {
+	return filepath.Join(models_root, task_id+".model")
+}
+
+// TrainingOutputs: every file a training run saving to model_path can leave behind: the model, its export, and their partial files.
+func TrainingOutputs(model_path string) []string {
+	export_path := forest.PathFor(model_path)
+	return []string{model_path, export_path, model_path + PartialSuffix, export_path + PartialSuffix}
+}
+
+// removeTrainingOutputs: removes what an unfinished training task left behind, unless the model was catalogued before the task could finish.
+func (self *Session) removeTrainingOutputs(model_path string) {
+	if slices.ContainsFunc(self.models, func(m Model) bool { return m.Path == model_path }) {
+		return
+	}
+	for _, path := range TrainingOutputs(model_path) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Print(err)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// workers: how many goroutines each concurrent test runs at once.
+const workers = 32
+
+// newTestSession: a session persisted to a fresh volume, closed when the test ends.
+func newTestSession(t *testing.T, volume string) *Session {
+	t.Helper()
+	var s Session
+	s.Setup(volume)
+	t.Cleanup(func() { s.Close() })
+	return &s
+}
+
+// distinct: fails the test if any ID was handed out twice.
+func distinct(t *testing.T, kind string, ids []string) {
+	t.Helper()
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("%s ID %s handed out twice", kind, id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestConcurrentCatalogue(t *testing.T) {
+	volume := t.TempDir()
+	s := newTestSession(t, volume)
+	shared := s.AddTask("infer")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var model_ids, task_ids, dataset_ids []string
+	started := 0
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			model := s.AddModel(Model{Name: "churn", Path: filepath.Join(volume, "models", fmt.Sprintf("t%d.model", i))})
+			if _, err := s.RegisterModel("churn", model.ID); err != nil {
+				t.Errorf("RegisterModel(%s): %s", model.ID, err)
+			}
+			task := s.AddTask("train")
+			if _, err := s.UpdateTask(task.ID, func(task *Task) error { return task.Transition(TaskRunning) }); err != nil {
+				t.Errorf("UpdateTask(%s): %s", task.ID, err)
+			}
+			// Every worker tries to start the same task; exactly one may
+			_, err := s.UpdateTask(shared.ID, func(task *Task) error { return task.Transition(TaskRunning) })
+			// Workers upload one of four files, so most uploads are duplicates
+			dataset, _ := s.AddReadyDataset(Dataset{Name: "churn", SHA256: fmt.Sprint(i % 4)})
+			s.Catalogue()
+			mu.Lock()
+			defer mu.Unlock()
+			model_ids = append(model_ids, model.ID)
+			task_ids = append(task_ids, task.ID)
+			dataset_ids = append(dataset_ids, dataset.ID)
+			if err == nil {
+				started++
+			}
+		}()
+	}
+	wg.Wait()
+
+	distinct(t, "model", model_ids)
+	distinct(t, "task", append(task_ids, shared.ID))
+	if started != 1 {
+		t.Errorf("shared task started %d times, want 1", started)
+	}
+	if task, _ := s.GetTask(shared.ID); task.Status != TaskRunning {
+		t.Errorf("shared task status %q, want %q", task.Status, TaskRunning)
+	}
+	datasets := s.Datasets()
+	if len(datasets) != 4 {
+		t.Fatalf("%d datasets catalogued, want 4", len(datasets))
+	}
+	versions := make(map[int]bool)
+	for _, dataset := range datasets {
+		versions[dataset.Version] = true
+	}
+	for version := 1; version <= 4; version++ {
+		if !versions[version] {
+			t.Errorf("no dataset is churn version %d", version)
+		}
+	}
+	for _, id := range dataset_ids {
+		if _, ok := s.GetDataset(id); !ok {
+			t.Errorf("AddReadyDataset returned %s, which is not catalogued", id)
+		}
+	}
+	registered, ok := s.GetRegisteredModel("churn")
+	if !ok {
+		t.Fatal("churn not registered")
+	}
+	if len(registered.Versions) != workers || registered.LatestVersion != workers {
+		t.Errorf("churn has %d versions, latest %d, want %d", len(registered.Versions), registered.LatestVersion, workers)
+	}
+}
+
+func TestConcurrentRollback(t *testing.T) {
+	s := newTestSession(t, t.TempDir())
+	for i := range workers {
+		model := s.AddModel(Model{Name: "churn", Path: fmt.Sprintf("t%d.model", i)})
+		if _, err := s.RegisterModel("churn", model.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.SetStage("churn", i+1, StageProduction); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each rollback steps one version back, so all but the last worker's succeed
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Rollback("churn"); err != nil {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failed != 1 {
+		t.Errorf("%d rollbacks failed, want 1", failed)
+	}
+	registered, _ := s.GetRegisteredModel("churn")
+	production, ok := registered.Production()
+	if !ok || production.Version != 1 {
+		t.Errorf("production is %+v, want version 1", production)
+	}
+	for _, version := range registered.Versions {
+		if version.Version != 1 && version.Stage != StageArchived {
+			t.Errorf("version %d is %s, want %s", version.Version, version.Stage, StageArchived)
+		}
+	}
+}
+
+func TestUpdateTaskKeepsTaskOnError(t *testing.T) {
+	s := newTestSession(t, t.TempDir())
+	task := s.AddTask("train")
+	_, err := s.UpdateTask(task.ID, func(task *Task) error {
+		task.Error = "half applied"
+		return task.Transition(TaskSucceeded)
+	})
+	if err == nil {
+		t.Fatal("queued task moved straight to succeeded")
+	}
+	got, _ := s.GetTask(task.ID)
+	if got.Error != "" || got.Status != TaskQueued {
+		t.Errorf("failed update changed the task to %+v", got)
+	}
+}
+
+func TestIDsSurviveRestart(t *testing.T) {
+	volume := t.TempDir()
+	var before []string
+	s := newTestSession(t, volume)
+	for range 3 {
+		model := s.AddModel(Model{Name: "churn"})
+		dataset, _ := s.AddReadyDataset(Dataset{Name: "churn", SHA256: model.ID})
+		result := s.AddResult(Result{ModelID: model.ID, DatasetID: dataset.ID})
+		task := s.AddTask("train")
+		before = append(before, model.ID, dataset.ID, result.ID, task.ID)
+	}
+	// The newest of each are deleted, so only the counters remember their IDs
+	s.RemoveModel("m3")
+	s.RemoveDataset("d3")
+	s.RemoveResult("r3")
+	s.RemoveTask("t3")
+	s.Close()
+
+	s = newTestSession(t, volume)
+	model := s.AddModel(Model{Name: "churn"})
+	dataset, _ := s.AddReadyDataset(Dataset{Name: "churn", SHA256: "new"})
+	result := s.AddResult(Result{ModelID: model.ID, DatasetID: dataset.ID})
+	task := s.AddTask("train")
+	tests := []struct {
+		kind string
+		got  string
+		want string
+	}{
+		{"model", model.ID, "m4"},
+		{"dataset", dataset.ID, "d4"},
+		{"result", result.ID, "r4"},
+		{"task", task.ID, "t4"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("first %s ID after restart is %s, want %s", test.kind, test.got, test.want)
+		}
+	}
+	if dataset.Version != 4 {
+		t.Errorf("first churn version after restart is %d, want 4", dataset.Version)
+	}
+	distinct(t, "catalogue", append(before, model.ID, dataset.ID, result.ID, task.ID))
+}
+
+func TestSetupRemovesInterruptedTrainingOutputs(t *testing.T) {
+	volume := t.TempDir()
+	s := newTestSession(t, volume)
+	models_root := filepath.Join(volume, "models")
+	interrupted := TrainedModelPath(models_root, s.AddTask("train").ID)
+	// Killed after the model was catalogued but before the task was marked succeeded
+	catalogued := TrainedModelPath(models_root, s.AddTask("train").ID)
+	s.AddModel(Model{Name: "churn", Path: catalogued})
+	kept := TrainingOutputs(catalogued)[:2]
+	for _, path := range append(TrainingOutputs(interrupted), kept...) {
+		if err := os.WriteFile(path, []byte("model"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	s = newTestSession(t, volume)
+	for _, path := range TrainingOutputs(interrupted) {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s left behind by an interrupted training task: %v", path, err)
+		}
+	}
+	for _, path := range kept {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("catalogued model file removed: %v", err)
+		}
+	}
+	if models := s.Models(); len(models) != 1 || models[0].Path != catalogued {
+		t.Errorf("models %+v, want only the catalogued one", models)
+	}
+}
+
+func TestDatasetFileShared(t *testing.T) {
+	s := newTestSession(t, t.TempDir())
+	dataset, _ := s.AddReadyDataset(Dataset{Name: "churn", SHA256: "abc"})
+	tests := []struct {
+		name       string
+		sha256     string
+		dataset_id string
+		want       bool
+	}{
+		{"upload not yet catalogued", "abc", "", true},
+		{"the dataset itself", "abc", dataset.ID, false},
+		{"another file", "def", "", false},
+	}
+	for _, test := range tests {
+		if got := s.DatasetFileShared(test.sha256, test.dataset_id); got != test.want {
+			t.Errorf("%s: shared %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names used by the catalogue store, one per resource type.
+const (
+	ModelsBucket   = "models"
+	DatasetsBucket = "datasets"
+	ResultsBucket  = "results"
+	TasksBucket    = "tasks"
+	// CountersBucket holds the IDAllocator's counters, keyed by counter.
+	CountersBucket = "counters"
+	// RegistryBucket holds the model registry, keyed by registered model name.
+	RegistryBucket = "registry"
+)
+
+// CatalogueFile is the name of the catalogue database created under the volume path.
+const CatalogueFile = "catalogue.db"
+
+// Store is the persistence layer behind a Session. Every catalogue entry is stored under its resource bucket keyed by ID.
+type Store interface {
+	// Load reads every persisted entry into the provided session.
+	Load(s *Session) error
+	// Put writes (or overwrites) a single entry.
+	Put(bucket string, id string, value any) error
+	// Delete removes a single entry. Deleting a missing entry is not an error.
+	Delete(bucket string, id string) error
+	Close() error
+}
+
+// BoltStore: a Store backed by an embedded bbolt database file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore: opens (or creates) the catalogue database at path and makes sure every bucket exists.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{ModelsBucket, DatasetsBucket, ResultsBucket, TasksBucket, CountersBucket, RegistryBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (self *BoltStore) Put(bucket string, id string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("unknown bucket: %s", bucket)
+		}
+		return b.Put([]byte(id), data)
+	})
+}
+
+func (self *BoltStore) Delete(bucket string, id string) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("unknown bucket: %s", bucket)
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+func (self *BoltStore) Load(s *Session) error {
+	return self.db.View(func(tx *bolt.Tx) error {
+		if err := loadBucket(tx, ModelsBucket, &s.models); err != nil {
+			return err
+		}
+		if err := loadBucket(tx, DatasetsBucket, &s.datasets); err != nil {
+			return err
+		}
+		if err := loadBucket(tx, ResultsBucket, &s.results); err != nil {
+			return err
+		}
+		if err := loadBucket(tx, TasksBucket, &s.tasks); err != nil {
+			return err
+		}
+		if err := loadBucket(tx, RegistryBucket, &s.registry); err != nil {
+			return err
+		}
+		if err := loadCounters(tx, CountersBucket, s.ids.restore); err != nil {
+			return err
+		}
+		// bbolt iterates keys lexicographically ("m10" before "m2"), so restore creation order
+		sort.SliceStable(s.models, func(i, j int) bool { return s.models[i].ID_num < s.models[j].ID_num })
+		sort.SliceStable(s.datasets, func(i, j int) bool { return s.datasets[i].ID_num < s.datasets[j].ID_num })
+		sort.SliceStable(s.results, func(i, j int) bool { return s.results[i].ID_num < s.results[j].ID_num })
+		sort.SliceStable(s.tasks, func(i, j int) bool { return s.tasks[i].ID_num < s.tasks[j].ID_num })
+		return nil
+	})
+}
+
+func (self *BoltStore) Close() error {
+	return self.db.Close()
+}
+
+// loadBucket: decodes every value in a bucket and appends it to the destination slice.
+func loadBucket[T any](tx *bolt.Tx, bucket string, dest *[]T) error {
+	b := tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+	return b.ForEach(func(k, v []byte) error {
+		var entry T
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return fmt.Errorf("decoding %s/%s: %w", bucket, k, err)
+		}
+		*dest = append(*dest, entry)
+		return nil
+	})
+}
+
+// loadCounters: decodes every counter in a bucket.
+func loadCounters(tx *bolt.Tx, bucket string, restore func(key string, n int)) error {
+	b := tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+	return b.ForEach(func(k, v []byte) error {
+		var n int
+		if err := json.Unmarshal(v, &n); err != nil {
+			return fmt.Errorf("decoding %s/%s: %w", bucket, k, err)
+		}
+		restore(string(k), n)
+		return nil
+	})
+}
+
+// MemoryStore: a Store that persists nothing. Used when the catalogue database cannot be opened.
+type MemoryStore struct{}
+
+func (MemoryStore) Load(s *Session) error                         { return nil }
+func (MemoryStore) Put(bucket string, id string, value any) error { return nil }
+func (MemoryStore) Delete(bucket string, id string) error         { return nil }
+func (MemoryStore) Close() error                                  { return nil }
+
+// catalogueStorePath: where the catalogue database lives for a given volume path.
+func catalogueStorePath(volumePath string) string {
+	return filepath.Join(volumePath, CatalogueFile)
+}
@@ -0,0 +1,94 @@
+package session
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Task lifecycle states. A task starts queued, moves to running, and finishes in exactly one terminal state.
+const (
+	TaskQueued    = "queued"
+	TaskRunning   = "running"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+	TaskCancelled = "cancelled"
+	TaskTimedOut  = "timed_out"
+)
+
+// taskTransitions: the states each task state is allowed to move to.
+var taskTransitions = map[string][]string{
+	TaskQueued:    {TaskRunning, TaskCancelled, TaskFailed},
+	TaskRunning:   {TaskSucceeded, TaskFailed, TaskCancelled, TaskTimedOut},
+	TaskSucceeded: {},
+	TaskFailed:    {},
+	TaskCancelled: {},
+	TaskTimedOut:  {},
+}
+
+// Finished: reports whether the task is in a terminal state.
+func (self *Task) Finished() bool {
+	return self.Status == TaskSucceeded || self.Status == TaskFailed || self.Status == TaskCancelled || self.Status == TaskTimedOut
+}
+
+// Transition: moves the task to a new state, stamping the start/finish times. Illegal transitions are rejected.
+func (self *Task) Transition(status string) error {
+	if !slices.Contains(taskTransitions[self.Status], status) {
+		return fmt.Errorf("task %s: illegal transition %s -> %s", self.ID, self.Status, status)
+	}
+	now := time.Now().UTC()
+	self.Status = status
+	if status == TaskRunning {
+		self.StartedAt = &now
+	}
+	if self.Finished() {
+		self.FinishedAt = &now
+	}
+	return nil
+}
+
+// AddTask: registers a new queued task of the given type and returns it.
+func (self *Session) AddTask(task_type string) Task {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	var new_task Task
+	new_task.ID_num, new_task.ID = self.nextID(TaskPrefix)
+	new_task.Type = task_type
+	new_task.Status = TaskQueued
+	new_task.CreatedAt = time.Now().UTC()
+	self.tasks = append(self.tasks, new_task)
+	self.saveTask(new_task)
+	return new_task
+}
+
+// GetTask: looks up a task by ID.
+func (self *Session) GetTask(id string) (Task, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	for _, task := range self.tasks {
+		if task.ID == id {
+			return task, true
+		}
+	}
+	return Task{}, false
+}
+
+// UpdateTask: applies an update to the task with the given ID and persists the result.
+// The update runs with the session locked, so it must not call back into the session.
+func (self *Session) UpdateTask(id string, update func(*Task) error) (Task, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for i := range self.tasks {
+		if self.tasks[i].ID == id {
+			// A failed update, such as an illegal transition, leaves the catalogued task as it was
+			task := self.tasks[i]
+			if err := update(&task); err != nil {
+				return self.tasks[i], err
+			}
+			self.tasks[i] = task
+			self.saveTask(task)
+			return task, nil
+		}
+	}
+	return Task{}, fmt.Errorf("task not found, id: %s", id)
+}
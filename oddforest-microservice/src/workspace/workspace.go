@@ -0,0 +1,80 @@
+package workspace
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Workspace: per-task working directories, keyed by task ID, under a common root. Each directory holds the task's config, logs and outputs.
+type Workspace struct {
+	Root string
+}
+
+// New: creates the workspace root if it does not exist yet.
+func New(root string) (*Workspace, error) {
+	if err := os.MkdirAll(root, 0777); err != nil {
+		return nil, err
+	}
+	return &Workspace{Root: root}, nil
+}
+
+// Create: creates the working directory for a task and returns its path.
+func (self *Workspace) Create(task_id string) (string, error) {
+	dir := self.Dir(task_id)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Dir: the working directory path for a task.
+func (self *Workspace) Dir(task_id string) string {
+	return filepath.Join(self.Root, filepath.Base(task_id))
+}
+
+// Remove: deletes a task's working directory and everything in it.
+func (self *Workspace) Remove(task_id string) error {
+	return os.RemoveAll(self.Dir(task_id))
+}
+
+// Collect: removes the working directories of tasks that finished more than retention ago, and returns the removed task IDs.
+// finished reports when a task finished; active is true while the task is still queued or running.
+// Directories with no known task fall back to their modification time.
+func (self *Workspace) Collect(retention time.Duration, finished func(task_id string) (finished_at time.Time, active bool, known bool)) []string {
+	var removed []string
+	entries, err := os.ReadDir(self.Root)
+	if err != nil {
+		log.Print(err)
+		return removed
+	}
+	cutoff := time.Now().Add(-retention)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		task_id := entry.Name()
+		finished_at, active, known := finished(task_id)
+		if active {
+			continue
+		}
+		if !known {
+			info, err := entry.Info()
+			if err != nil {
+				log.Print(err)
+				continue
+			}
+			finished_at = info.ModTime()
+		}
+		if finished_at.After(cutoff) {
+			continue
+		}
+		if err := self.Remove(task_id); err != nil {
+			log.Print(err)
+			continue
+		}
+		removed = append(removed, task_id)
+	}
+	return removed
+}
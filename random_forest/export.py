@@ -0,0 +1,107 @@
+# Copyright (c) 2025 Intel Corporation.
+
+# Permission is hereby granted, free of charge, to any person obtaining a copy
+# of this software and associated documentation files (the "Software"), to deal
+# in the Software without restriction, including without limitation the rights
+# to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+# copies of the Software, and to permit persons to whom the Software is
+# furnished to do so, subject to the following conditions:
+
+# The above copyright notice and this permission notice shall be included in
+# all copies or substantial portions of the Software.
+
+# THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+# IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+# FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+# AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+# LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+# OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+# SOFTWARE.
+
+"""
+Code that exports a trained random forest into a portable JSON format the API server can evaluate without Python
+"""
+import json
+
+# Version of the exported forest format read by the API server
+FORMAT_VERSION = 2
+
+def label(value):
+    """
+    Class labels are exported as strings, converting numpy scalars to their Python value first
+    """
+    if hasattr(value, "item"):
+        value = value.item()
+    return str(value)
+
+def export_node(node, regression=False):
+    """
+    Exports a tree node and its children. A node is only exported as a split if the Python predictor would split there.
+    Classification nodes carry the class they predict; regression nodes the value they predict and their number of observations.
+    """
+    if regression:
+        exported = {"value": float(node.yhat), "samples": int(node.n)}
+    else:
+        exported = {
+            "prediction": label(node.yhat),
+            "counts": {label(k): int(v) for k, v in node.counts.items()},
+        }
+    is_split = (
+        node.depth < node.max_depth
+        and node.n >= node.min_samples_split
+        and node.best_feature is not None
+        and node.left is not None
+        and node.right is not None
+    )
+    if is_split:
+        exported["feature"] = node.best_feature
+        exported["threshold"] = float(node.best_value)
+        exported["left"] = export_node(node.left, regression)
+        exported["right"] = export_node(node.right, regression)
+    return exported
+
+def classes(rf):
+    """
+    The classes a classifier was trained on, sorted. Empty for regression forests
+    """
+    if getattr(rf, "model_type", "classification") == "regression":
+        return []
+    return sorted({label(y) for y in rf.Y})
+
+def export_bins(opti_array):
+    """
+    Exports the optimized data discretization bin edges used for each feature's splits
+    """
+    if not opti_array:
+        return {}
+    return {feature: [float(edge) for edge in edges] for feature, edges in opti_array.items() if edges is not None}
+
+def export_forest(rf, infer_name):
+    """
+    Exports a grown RandomForestClassifier or RandomForestRegressor. The format is documented in docs/user-guide/model-format.md.
+    """
+    model_type = getattr(rf, "model_type", "classification")
+    regression = model_type == "regression"
+    return {
+        "format_version": FORMAT_VERSION,
+        "model_type": model_type,
+        "features": list(rf.features),
+        "infer_name": infer_name,
+        "classes": classes(rf),
+        "hyperparameters": {
+            "n_trees": int(rf.n_trees),
+            "max_depth": int(rf.max_depth),
+            "min_samples_split": int(rf.min_samples_split),
+            "x_features_fraction": float(rf.X_features_fraction),
+            "x_obs_fraction": float(rf.X_obs_fraction),
+        },
+        "odd_bins": export_bins(rf.opti_array),
+        "trees": [export_node(tree, regression) for tree in rf.random_forest],
+    }
+
+def write_forest(rf, infer_name, path):
+    """
+    Writes the exported forest to disk
+    """
+    with open(path, 'w') as f:
+        json.dump(export_forest(rf, infer_name), f)
@@ -22,15 +22,18 @@
 Code that houses the class that creates and uses the random forest classifier 
 """
 import argparse
+import contextlib
+import io
+import json
+import os
 import tomllib
 #TODO: Look into INtel Distribution of Modin
 import pandas as pd 
 #TODO: Look into intel extension for scikit-learn 
-from sklearn.metrics import precision_score, recall_score
 from sklearn.model_selection import train_test_split
 import joblib
 
-import odd, randomforestclassifier
+import export, metrics, odd, randomforestclassifier, randomforestregressor, schema
 
 # argparse for command-line arguments like config file location
 parser = argparse.ArgumentParser(
@@ -39,163 +42,281 @@ parser = argparse.ArgumentParser(
         Optimzied Data Discretion bins your input data to create a smaller, faster, and highly accurate model tailored to your use case.",
     epilog= "Copyright 2023 Intel Corporation. Distributed under (license)")
 parser.add_argument('config_filepath')
+parser.add_argument('--results', help="path to write the JSON result envelope to")
+
+# Version of the JSON result envelope read by the API server
+RESULTS_VERSION = 3
+
+# Suffix of the model and export files while training writes them. They are renamed into place once the metrics are in,
+# so an interrupted or failed run never leaves a file at the path the API server adopts models from
+PARTIAL_SUFFIX = ".partial"
+
+class RunnerError(Exception):
+    """
+    Error reported back to the API server in the result envelope
+    """
+    def __init__(self, kind, message):
+        super().__init__(message)
+        self.kind = kind
+        self.message = message
+
+# Write the result envelope for the API server. Without --results the envelope goes to stdout as the final line.
+def write_results(filepath, task, payload=None, error=None):
+    envelope = {"version": RESULTS_VERSION, "task": task, "status": "ok" if error is None else "error"}
+    if error is not None:
+        envelope["error"] = {"type": error.kind, "message": error.message}
+    if payload:
+        envelope.update(payload)
+    if filepath:
+        with open(filepath, 'w') as f:
+            json.dump(envelope, f)
+    else:
+        print(json.dumps(envelope))
 
 # Parse provided TOML config file
 def parse_config_file(filepath):
     try:
-        try:
-            with open(filepath, 'rb') as f:
-                config = tomllib.load(f)
-                return config
-        except tomllib.TOMLDecodeError:
-            #TODO log tomllib decode error in global logger
-            print("Error decoding TOML file - please check provided file.")
-            exit()
-    except OSError:
-        print("Problem opening provided file: " + filepath)
-        exit()
-
-# Handle reading input data from .csv
-def read_input_data(filepath):
+        with open(filepath, 'rb') as f:
+            config = tomllib.load(f)
+            return config
+    except tomllib.TOMLDecodeError as e:
+        raise RunnerError("config", "Error decoding TOML file - please check provided file: " + str(e))
+    except OSError as e:
+        raise RunnerError("config", "Problem opening provided file: " + filepath + ": " + str(e))
+
+# Handle reading input data in the format the API server detected on upload: csv, csv.gz, jsonl or parquet. Datasets without a recorded format are CSV
+def read_input_data(filepath, input_format=None):
     try:
-        f = pd.read_csv(filepath)
+        if input_format == "parquet":
+            return pd.read_parquet(filepath)
+        if input_format == "jsonl":
+            return pd.read_json(filepath, lines=True, convert_dates=False)
+        f = pd.read_csv(filepath, compression="gzip" if input_format == "csv.gz" else "infer")
         return f
     except Exception as e:
-        #TODO Log error
-        print("Error with provided filepath + ", e)
-        exit()
+        raise RunnerError("dataset", "Error with provided filepath " + filepath + ": " + str(e))
 
-def inference(config, rf=None, splitdata=None):
+# Load a model from disk
+def load_model(path):
     try:
-        # load in model
-        if rf == None:
-            try:
-                rf = joblib.load(config["path"])
-            except FileNotFoundError:
-                print("model not found, exiting...")
-                exit
-        if rf == "empty":
-            print(0)
-            print(0)
-            return
-
-        if not isinstance(splitdata, pd.DataFrame):
-            d = read_input_data(config["input_data"])
-        else:
-            d = splitdata
+        return joblib.load(path)
+    except FileNotFoundError:
+        raise RunnerError("model", "model not found: " + path)
+
+def inference(config, rf=None, splitdata=None):
+    """
+    Runs the model over a dataset and returns its metrics, or None for an empty model or a dataset without the label column
+    """
+    # load in model
+    if rf is None:
+        rf = load_model(config["path"])
+    if rf == "empty":
+        return None
 
-        features = config["features"]
-        yhat = rf.predict(d[features])
-        d['yhat'] = yhat 
+    if not isinstance(splitdata, pd.DataFrame):
+        d = read_input_data(config["input_data"], config.get("input_format"))
+    else:
+        d = splitdata
 
-        # Measurring accuracy
-        print(f"{precision_score(d[config['y_axis']], d['yhat'])}")
-        print(f"{recall_score(d[config['y_axis']], d['yhat'])}")
+    tree_yhat = predict(rf, d, config["features"])
+    return score(config, rf, d, tree_yhat)
 
-    except Exception as e:
-        print(e)
+def predict(rf, d, features):
+    """
+    Predicts every row of the dataset into d['yhat'] and returns each tree's predictions
+    """
+    tree_yhat = rf.tree_predictions(d[features])
+    d['yhat'] = rf.aggregate(tree_yhat)
+    return tree_yhat
+
+def score(config, rf, d, tree_yhat):
+    """
+    Returns the metrics of the predictions in d['yhat'], or None for a dataset without the label column
+    """
+    # Measurring accuracy, if the dataset has the label column
+    if config['y_axis'] not in d.columns:
+        return None
+    if is_regression(rf):
+        return metrics.regression_report(d[config['y_axis']], d['yhat'])
+    return metrics.classification_report(d[config['y_axis']], d['yhat'], tree_yhat, config.get("average"), labels=export.classes(rf))
+
+def is_regression(rf):
+    """
+    Whether a model is a regression forest. Models saved before regression support are classifiers
+    """
+    return getattr(rf, "model_type", "classification") == "regression"
+
+def infer(config):
+    """
+    Runs the infer task: predicts every row of the dataset, keeps the predictions if asked to, and scores them if the dataset is labelled
+    """
+    rf = load_model(config["path"])
+    d = read_input_data(config["input_data"], config.get("input_format"))
+    tree_yhat = predict(rf, d, config["features"])
+    scores = score(config, rf, d, tree_yhat)
+    if config.get("predictions_path"):
+        write_predictions(rf, d, tree_yhat, config["predictions_path"])
+    if is_regression(rf):
+        return {"rows": len(d), "metrics": None, "regression_metrics": scores}
+    return {"rows": len(d), "metrics": scores}
+
+def write_predictions(rf, d, tree_yhat, path):
+    """
+    Writes each row's id and prediction, and for classifiers the share of trees that voted for the predicted class. Expects predict to have filled in d['yhat'] and returned tree_yhat
+    """
+    if is_regression(rf):
+        predictions = pd.DataFrame({"row_id": d.index, "prediction": d['yhat']})
+        try:
+            predictions.to_csv(path, index=False)
+        except OSError as e:
+            raise RunnerError("results", "could not write predictions to " + path + ": " + str(e))
+        return
+    vote_share = [
+        sum(1 for votes in tree_yhat if votes[i] == prediction) / len(tree_yhat)
+        for i, prediction in enumerate(d['yhat'])
+    ]
+    predictions = pd.DataFrame({
+        "row_id": d.index,
+        "prediction": [export.label(y) for y in d['yhat']],
+        "vote_share": vote_share,
+    })
+    try:
+        predictions.to_csv(path, index=False)
+    except OSError as e:
+        raise RunnerError("results", "could not write predictions to " + path + ": " + str(e))
 
 def show_trees(config, rf=None):
-    if rf == None:
+    """
+    Returns the printed representation of every tree in the model, one line per entry
+    """
+    if rf is None:
+        rf = load_model(config["path"])
+    buf = io.StringIO()
+    with contextlib.redirect_stdout(buf):
+        rf.print_trees()
+    return buf.getvalue().splitlines()
+
+def profile(config):
+    """
+    Runs the profile task: the dataset's row count and the type, missing values, range and class balance of every column
+    """
+    d = read_input_data(config["input_data"], config.get("input_format"))
+    return schema.profile(d)
+
+def train(config):
+    """
+    Trains the optimized (and optionally unoptimized) forest, saves it, and returns its metrics
+    """
+    d = read_input_data(config["input_data"], config.get("input_format"))
+        # Setting the features used
+    features = config["features"]
+    print(features)
+    # Grow the forest on data_split of the rows only, and halve the rest into the held-out validation and test sets
+    d_train, d_holdout = train_test_split(d, train_size=config["data_split"])
+    d_validation, d_test = train_test_split(d_holdout, test_size=0.5)
+    opt_array={i: odd.automated_optimal_binning(d_train[i].values)[2] for i in features}
+    print("data binned")
+    # Regression forests predict a numeric target
+    regression = config.get("model_type") == "regression"
+    forest_class = randomforestregressor.RandomForestRegressor if regression else randomforestclassifier.RandomForestClassifier
+    # Create the random forest without optimized data
+    if config["show_unoptimized"] == True:
+        rf_unopt = forest_class(
+            Y=d_train[config['y_axis']], 
+            X=d_train[features],
+            min_samples_split=config["min_samples_split"],
+            max_depth=config["max_depth"],
+            n_trees=config["n_trees"],
+            X_features_fraction=config["x_features_fraction"],
+            opti_array=opt_array,
+        )
+        print("rf classifier created")
+        print("rf forest growing")
+        # Growing the random forest 
+        rf_unopt.grow_random_forest()
+    else:
+        rf_unopt = "empty"
+    # Create the random forest for optimized data
+    rf = forest_class(
+        Y=d_train[config['y_axis']], 
+        X=d_train[features],
+        min_samples_split=config["min_samples_split"],
+        max_depth=config["max_depth"],
+        n_trees=config["n_trees"],
+        X_features_fraction=config["x_features_fraction"],
+        opti_array=opt_array,
+    )
+    print("rf classifier created")
+    print("rf forest growing")
+    # Growing the random forest 
+    rf.grow_random_forest()
+
+    # Save random forest to disk, under a temporary name until it has been scored
+    print("saving classifier to disk")
+    model_path, export_path = config["path"], config.get("export_path")
+    partial_paths = {model_path: model_path + PARTIAL_SUFFIX}
+    if export_path:
+        partial_paths[export_path] = export_path + PARTIAL_SUFFIX
+    try:
         try:
-            rf = joblib.load(config["path"])
-            rf.print_trees()
-        except FileNotFoundError:
-            print("model not found, exiting...")
-            exit
-    
+            joblib.dump(rf, partial_paths[model_path], 3,5)
+        except OSError as e:
+            raise RunnerError("model", "could not save model to " + model_path + ": " + str(e))
+        # Save the portable export alongside it for the API server's native inference
+        if export_path:
+            try:
+                export.write_forest(rf, config["y_axis"], partial_paths[export_path])
+            except OSError as e:
+                raise RunnerError("model", "could not export model to " + export_path + ": " + str(e))
+        scores = {
+            "unoptimized_validation": inference(config, rf_unopt, d_validation),
+            "unoptimized_test": inference(config, rf_unopt, d_test),
+            "validation": inference(config, rf, d_validation),
+            "test": inference(config, rf, d_test),
+        }
+        for path, partial_path in partial_paths.items():
+            try:
+                os.replace(partial_path, path)
+            except OSError as e:
+                raise RunnerError("model", "could not save model to " + path + ": " + str(e))
+    finally:
+        for partial_path in partial_paths.values():
+            with contextlib.suppress(FileNotFoundError):
+                os.remove(partial_path)
+    return {"regression_training" if regression else "training": scores}
 
 if __name__ == '__main__':
     # Reading data for classification
     
     args = parser.parse_args()
-    config = parse_config_file(args.config_filepath)
-    print(config)
+    task = None
     try:
-        if config["task"] == "train": 
-            d = read_input_data(config["input_data"])
-                # Setting the features used
-            features = config["features"]
-            print(features)
-            # Get our train/test split
-            d_test, d_train = train_test_split(d, test_size=config["data_split"])
-            try:
-                opt_array={i: odd.automated_optimal_binning(d_train[i].values)[2] for i in features}
-            except Exception as e:
-                    print(e)
-            print("data binned")
-            # Create the random forest without optimized data
-            if config["show_unoptimized"] == True:
-                try: 
-                    rf_unopt = randomforestclassifier.RandomForestClassifier(
-                        Y=d[config['y_axis']], 
-                        X=d[features],
-                        min_samples_split=config["min_samples_split"],
-                        max_depth=config["max_depth"],
-                        n_trees=config["n_trees"],
-                        X_features_fraction=config["x_features_fraction"],
-                        opti_array=opt_array,
-                    )
-                except Exception as e:
-                    print(e)
-                    exit()
-                print("rf classifier created")
-                print("rf forest growing")
-                # Growing the random forest 
-                rf_unopt.grow_random_forest()
-            else:
-                rf_unopt = "empty"
-            # Create the random forest for optimized data
-            try: 
-                rf = randomforestclassifier.RandomForestClassifier(
-                    Y=d[config['y_axis']], 
-                    X=d[features],
-                    min_samples_split=config["min_samples_split"],
-                    max_depth=config["max_depth"],
-                    n_trees=config["n_trees"],
-                    X_features_fraction=config["x_features_fraction"],
-                    opti_array=opt_array,
-                )
-            except Exception as e:
-                print(e)
-                exit()
-            print("rf classifier created")
-            print("rf forest growing")
-            # Growing the random forest 
-            rf.grow_random_forest()
-
-            # Save random forest to disk
-            try:
-                print("saving classifier to disk")
-                try:
-                    joblib.dump(rf, config["path"], 3,5)
-                except Exception as e:
-                    print(e)
-            except FileNotFoundError as e:
-                try:
-                    joblib.dump(rf, "test.model", 3,5)
-                    print("error", e)
-                except Exception as e:
-                    print(e)
-            # Printing out the trees 
-            #rf.print_trees()
-            rf.print_trees
-            inference(config, rf_unopt, d_train)
-            inference(config, rf_unopt, d_test)
-            inference(config, rf, d_train)
-            inference(config, rf, d_test)
+        config = parse_config_file(args.config_filepath)
+        print(config)
+        task = config["task"]
+        if task == "train": 
+            payload = train(config)
 
         # Making predictions
-        if config["task"] == "infer":
-            inference(config)
+        elif task == "infer":
+            payload = {"inference": infer(config)}
 
-        if config["task"] == "show_trees":
-            show_trees(config)
+        elif task == "show_trees":
+            payload = {"trees": show_trees(config)}
 
-        if config["task"] != "infer" and config["task"] != "train" and config["tast"] != "show_trees":
-            print("Incorrect task type selected. Please choose from infer or train")
-        
+        elif task == "profile":
+            payload = {"schema": profile(config)}
 
+        else:
+            raise RunnerError("config", "Incorrect task type selected. Please choose from infer, train, show_trees or profile")
+        write_results(args.results, task, payload)
+
+    except RunnerError as e:
+        write_results(args.results, task, error=e)
+        exit(1)
     except KeyError as e:
-        print("Issue with provided config - please check your provided TOML configuration. " + e)
-        exit()
\ No newline at end of file
+        write_results(args.results, task, error=RunnerError("config", "Issue with provided config or dataset - missing key " + str(e)))
+        exit(1)
+    except Exception as e:
+        write_results(args.results, task, error=RunnerError(type(e).__name__, str(e)))
+        exit(1)
@@ -0,0 +1,116 @@
+# Copyright (c) 2025 Intel Corporation.
+
+# Permission is hereby granted, free of charge, to any person obtaining a copy
+# of this software and associated documentation files (the "Software"), to deal
+# in the Software without restriction, including without limitation the rights
+# to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+# copies of the Software, and to permit persons to whom the Software is
+# furnished to do so, subject to the following conditions:
+
+# The above copyright notice and this permission notice shall be included in
+# all copies or substantial portions of the Software.
+
+# THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+# IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+# FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+# AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+# LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+# OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+# SOFTWARE.
+
+"""
+Classification and regression metrics for training and inference, computed the same way as the API server's metrics package
+"""
+from sklearn.metrics import accuracy_score, confusion_matrix, mean_absolute_error, mean_squared_error, precision_recall_fscore_support, r2_score, roc_auc_score
+
+import export
+
+def vote_fractions(tree_yhat, cls):
+    """
+    The share of trees that voted for a class, for each row
+    """
+    n_trees = len(tree_yhat)
+    return [sum(1 for votes in tree_yhat if export.label(votes[i]) == cls) / n_trees for i in range(len(tree_yhat[0]))]
+
+def class_auc(y, tree_yhat, cls):
+    """
+    One-vs-rest ROC AUC of a class, scoring each row by its vote fraction. None unless the class has both positive and negative rows
+    """
+    positives = [label == cls for label in y]
+    if all(positives) or not any(positives):
+        return None
+    return float(roc_auc_score(positives, vote_fractions(tree_yhat, cls)))
+
+def class_weight(per_class, cls, average):
+    """
+    The weight of a class when averaging over classes
+    """
+    return per_class[cls]["support"] if average == "weighted" else 1
+
+def classification_report(y, yhat, tree_yhat=None, average=None, labels=None):
+    """
+    Scores predicted classes against the true labels. labels are the classes the model was trained on; the report covers them
+    and any other label seen in y or yhat, so the averaging and positive class do not depend on which classes the rows hold.
+    average is binary, macro, micro or weighted. Without one, or with binary and more than two classes, two classes are scored
+    as binary and more as macro. Binary reports the scores of the positive class, the last class in sorted order
+    """
+    y = [export.label(label) for label in y]
+    yhat = [export.label(label) for label in yhat]
+    classes = sorted(set(labels or []) | set(y) | set(yhat))
+    precision, recall, f1, support = precision_recall_fscore_support(y, yhat, labels=classes, zero_division=0)
+    per_class = {
+        cls: {"precision": float(precision[i]), "recall": float(recall[i]), "f1": float(f1[i]), "support": int(support[i])}
+        for i, cls in enumerate(classes)
+    }
+    report = {
+        "accuracy": float(accuracy_score(y, yhat)),
+        "roc_auc": None,
+        "classes": classes,
+        "confusion_matrix": confusion_matrix(y, yhat, labels=classes).tolist(),
+        "per_class": per_class,
+    }
+    if not average or average == "binary":
+        average = "binary" if len(classes) <= 2 else "macro"
+    report["average"] = average
+
+    if average == "binary":
+        positive = classes[-1]
+        report.update({
+            "positive_class": positive,
+            "precision": per_class[positive]["precision"],
+            "recall": per_class[positive]["recall"],
+            "f1": per_class[positive]["f1"],
+        })
+        if tree_yhat:
+            report["roc_auc"] = class_auc(y, tree_yhat, positive)
+        return report
+
+    if average == "micro":
+        # Every row is one prediction, so pooled precision and recall are both the share of rows predicted correctly
+        report.update({"precision": report["accuracy"], "recall": report["accuracy"], "f1": report["accuracy"]})
+    else:
+        total = sum(class_weight(per_class, cls, average) for cls in classes)
+        for key in ("precision", "recall", "f1"):
+            weighted = sum(class_weight(per_class, cls, average) * per_class[cls][key] for cls in classes)
+            report[key] = weighted / total if total else 0.0
+    if tree_yhat:
+        # One-vs-rest AUC averaged over the classes it is defined for, weighted by support for weighted averaging
+        aucs = [(cls, class_auc(y, tree_yhat, cls)) for cls in classes]
+        aucs = [(class_weight(per_class, cls, average), auc) for cls, auc in aucs if auc is not None]
+        total = sum(weight for weight, _ in aucs)
+        if total:
+            report["roc_auc"] = sum(weight * auc for weight, auc in aucs) / total
+    return report
+
+def regression_report(y, yhat):
+    """
+    Scores predicted values against the true targets
+    """
+    y = [float(value) for value in y]
+    yhat = [float(value) for value in yhat]
+    # R² of constant targets is 1 for a perfect fit and 0 otherwise, rather than undefined
+    return {
+        "rmse": float(mean_squared_error(y, yhat) ** 0.5),
+        "mae": float(mean_absolute_error(y, yhat)),
+        "r2": float(r2_score(y, yhat, force_finite=True)) if len(y) > 1 else (1.0 if y == yhat else 0.0),
+    }
@@ -27,6 +27,12 @@ class RandomForestClassifier():
     """
     Class that creates a random forest for classification problems
     """
+    # Kind of model, as recorded in the portable export
+    model_type = "classification"
+
+    # Tree grown for each member of the forest
+    tree_class = randomforesttree.RandomForestTree
+
     def __init__(
         self,
         Y: list,
@@ -91,7 +97,7 @@ class RandomForestClassifier():
             X, Y = self.bootstrap_sample()
             
             # Initiating the random tree
-            tree = randomforesttree.RandomForestTree(
+            tree = self.tree_class(
                 Y=Y, 
                 X=X, 
                 min_samples_split=self.min_samples_split,
@@ -138,10 +144,14 @@ class RandomForestClassifier():
         Method to get the final prediction of the whole random forest 
         """
         # Getting the individual tree predictions
-        yhat = self.tree_predictions(X)
+        return self.aggregate(self.tree_predictions(X))
 
-        # Saving the number of obs in X 
-        n = X.shape[0]
+    def aggregate(self, yhat: list) -> list:
+        """
+        Method to combine the predictions from all the trees into the forest's prediction
+        """
+        # Saving the number of obs in the predictions 
+        n = len(yhat[0]) if yhat else 0
 
         # Getting the majority vote of each coordinate of the prediction list 
         yhat_final = []
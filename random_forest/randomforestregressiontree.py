@@ -0,0 +1,132 @@
+# Copyright (c) 2025 Intel Corporation.
+
+# Permission is hereby granted, free of charge, to any person obtaining a copy
+# of this software and associated documentation files (the "Software"), to deal
+# in the Software without restriction, including without limitation the rights
+# to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+# copies of the Software, and to permit persons to whom the Software is
+# furnished to do so, subject to the following conditions:
+
+# The above copyright notice and this permission notice shall be included in
+# all copies or substantial portions of the Software.
+
+# THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+# IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+# FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+# AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+# LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+# OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+# SOFTWARE.
+
+"""
+Code that houses the class that grows one regression tree of a random forest
+"""
+import random
+import numpy as np
+
+import randomforesttree
+
+class RandomForestRegressionTree(randomforesttree.RandomForestTree):
+    """
+    Class that grows one random forest tree for a numeric target, splitting on variance reduction
+    """
+    def __init__(self, Y, X, **kwargs):
+        super().__init__(Y, X, **kwargs)
+
+        # This node will predict the mean target of its observations
+        self.yhat = float(np.mean(Y)) if len(Y) > 0 else None
+
+    @staticmethod
+    def variance(Y) -> float:
+        """
+        Given the targets in a node calculate their variance
+        """
+        # If there are no observations we return the lowest possible variance
+        if len(Y) == 0:
+            return 0.0
+
+        return float(np.var(Y))
+
+    def get_GINI(self):
+        """
+        The impurity of a regression node is the variance of its targets
+        """
+        return self.variance(self.Y)
+
+    def best_split(self) -> tuple:
+        """
+        Given the X features and Y targets calculates the split with the largest variance reduction
+        """
+        # Creating a dataset for spliting
+        Xdf = self.X.copy()
+        Xdf['Y'] = self.Y
+
+        # Getting the variance for the base input
+        variance_base = self.get_GINI()
+
+        # Finding which split yields the best variance reduction
+        max_gain = 0
+
+        # Default best feature and split
+        best_feature = None
+        best_value = None
+
+        # Getting a random subsample of features
+        n_ft = int(self.n_features * self.X_features_fraction)
+
+        # Selecting random features without repetition
+        features_subsample = random.sample(self.features, n_ft)
+
+        for feature in features_subsample:
+            # Using the optimized bins as candidate splits when we have them
+            xmeans = None
+            if self.opti_array is not None:
+                if feature in self.opti_array:
+                    xmeans = self.opti_array[feature]
+            if xmeans is None:
+                Xdf = Xdf.dropna().sort_values(feature)
+                xmeans = self.ma(Xdf[feature].unique(), 2)
+
+            for value in xmeans:
+                # Spliting the dataset
+                left_Y = Xdf[Xdf[feature]<value]['Y']
+                right_Y = Xdf[Xdf[feature]>=value]['Y']
+
+                # Getting the obs count from the left and the right data splits
+                n_left = len(left_Y)
+                n_right = len(right_Y)
+                if n_left + n_right == 0:
+                    continue
+
+                # Calculating the weighted variance of the two nodes
+                wvariance = (n_left * self.variance(left_Y) + n_right * self.variance(right_Y)) / (n_left + n_right)
+
+                # Calculating the variance reduction
+                gain = variance_base - wvariance
+
+                # Checking if this is the best split so far
+                if gain > max_gain:
+                    best_feature = feature
+                    best_value = value
+
+                    # Setting the best gain to the current one
+                    max_gain = gain
+
+        return (best_feature, best_value)
+
+    def print_info(self, width=4):
+        """
+        Method to print the infromation about the tree
+        """
+        # Defining the number of spaces
+        const = int(self.depth * width ** 1.5)
+        spaces = "-" * const
+
+        if self.node_type == 'root':
+            print("Root")
+        else:
+            print(f"|{spaces} Split rule: {self.rule}")
+        print(f"{' ' * const}   | Tree Depth: {self.depth}")
+        print(f"{' ' * const}   | Variance of the node: {round(self.gini_impurity, 2)}")
+        print(f"{' ' * const}   | Observations in the node: {self.n}")
+        print(f"{' ' * const}   | Predicted value: {self.yhat}")
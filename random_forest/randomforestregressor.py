@@ -0,0 +1,44 @@
+# Copyright (c) 2025 Intel Corporation.
+
+# Permission is hereby granted, free of charge, to any person obtaining a copy
+# of this software and associated documentation files (the "Software"), to deal
+# in the Software without restriction, including without limitation the rights
+# to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+# copies of the Software, and to permit persons to whom the Software is
+# furnished to do so, subject to the following conditions:
+
+# The above copyright notice and this permission notice shall be included in
+# all copies or substantial portions of the Software.
+
+# THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+# IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+# FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+# AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+# LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+# OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+# SOFTWARE.
+
+"""
+Code that houses the class that creates and uses the random forest regressor
+"""
+import numpy as np
+
+import randomforestclassifier, randomforestregressiontree
+
+class RandomForestRegressor(randomforestclassifier.RandomForestClassifier):
+    """
+    Class that creates a random forest for regression problems. Trees split on variance reduction and the forest predicts their mean
+    """
+    # Kind of model, as recorded in the portable export
+    model_type = "regression"
+
+    # Tree grown for each member of the forest
+    tree_class = randomforestregressiontree.RandomForestRegressionTree
+
+    def aggregate(self, yhat: list) -> list:
+        """
+        Method to combine the predictions from all the trees into the forest's prediction: their mean
+        """
+        if not yhat:
+            return []
+        return [float(value) for value in np.mean(np.array(yhat, dtype=float), axis=0)]
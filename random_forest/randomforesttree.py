@@ -109,31 +109,23 @@ class RandomForestTree():
         return X 
 
     @staticmethod
-    def GINI_impurity(y1_count: int, y2_count: int) -> float:
+    def GINI_impurity(*class_counts: int) -> float:
         """
-        Given the observations of a binary class calculate the GINI impurity
+        Given the observations of each class calculate the GINI impurity
         """
         # Ensuring the correct types
-        if y1_count is None:
-            y1_count = 0
-
-        if y2_count is None:
-            y2_count = 0
+        class_counts = [count if count is not None else 0 for count in class_counts]
 
         # Getting the total observations
-        n = y1_count + y2_count
-        
+        n = sum(class_counts)
+
         # If n is 0 then we return the lowest possible gini impurity
         if n == 0:
             return 0.0
 
-        # Getting the probability to see each of the classes
-        p1 = y1_count / n
-        p2 = y2_count / n
-        
-        # Calculating GINI 
-        gini = 1 - (p1 ** 2 + p2 ** 2)
-        
+        # Calculating GINI from the probability to see each of the classes
+        gini = 1 - sum((count / n) ** 2 for count in class_counts)
+
         # Returning the gini impurity
         return gini
 
@@ -148,11 +140,8 @@ class RandomForestTree():
         """
         Function to calculate the GINI impurity of a node 
         """
-        # Getting the 0 and 1 counts
-        y1_count, y2_count = self.counts.get(0, 0), self.counts.get(1, 0)
-
-        # Getting the GINI impurity
-        return self.GINI_impurity(y1_count, y2_count)
+        # Getting the GINI impurity over every class in the node
+        return self.GINI_impurity(*self.counts.values())
 
     def best_split(self) -> tuple:
         """
@@ -199,16 +188,13 @@ class RandomForestTree():
                 left_counts = Counter(Xdf[Xdf[feature]<value]['Y'])
                 right_counts = Counter(Xdf[Xdf[feature]>=value]['Y'])
 
-                # Getting the Y distribution from the dicts
-                y0_left, y1_left, y0_right, y1_right = left_counts.get(0, 0), left_counts.get(1, 0), right_counts.get(0, 0), right_counts.get(1, 0)
-
-                # Getting the left and right gini impurities
-                gini_left = self.GINI_impurity(y0_left, y1_left)
-                gini_right = self.GINI_impurity(y0_right, y1_right)
+                # Getting the left and right gini impurities from the Y distributions
+                gini_left = self.GINI_impurity(*left_counts.values())
+                gini_right = self.GINI_impurity(*right_counts.values())
 
                 # Getting the obs count from the left and the right data splits
-                n_left = y0_left + y1_left
-                n_right = y0_right + y1_right
+                n_left = sum(left_counts.values())
+                n_right = sum(right_counts.values())
 
                 # Calculating the weights for each of the nodes
                 w_left = n_left / (n_left + n_right)
@@ -259,7 +245,7 @@ class RandomForestTree():
                 left_Y, right_Y = [self.Y[x] for x in left_index], [self.Y[x] for x in right_index]
 
                 # Creating the left and right nodes
-                left = RandomForestTree(
+                left = type(self)(
                     left_Y, 
                     left_X, 
                     depth=self.depth + 1, 
@@ -273,7 +259,7 @@ class RandomForestTree():
                 self.left = left 
                 self.left.grow_tree()
 
-                right = RandomForestTree(
+                right = type(self)(
                     right_Y, 
                     right_X, 
                     depth=self.depth + 1, 
@@ -0,0 +1,67 @@
+# Copyright (c) 2025 Intel Corporation.
+
+# Permission is hereby granted, free of charge, to any person obtaining a copy
+# of this software and associated documentation files (the "Software"), to deal
+# in the Software without restriction, including without limitation the rights
+# to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+# copies of the Software, and to permit persons to whom the Software is
+# furnished to do so, subject to the following conditions:
+
+# The above copyright notice and this permission notice shall be included in
+# all copies or substantial portions of the Software.
+
+# THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+# IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+# FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+# AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+# LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+# OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+# SOFTWARE.
+
+"""
+Dataset profiles for the formats the API server does not read itself, laid out the same way as its datafile.Schema
+"""
+import math
+
+import pandas as pd
+
+# Most distinct values a column can have and still get a class balance, as in datafile.MaxClasses
+MAX_CLASSES = 20
+
+def column_type(values):
+    """
+    The type of a column from its non-missing values: integer, float, string or empty
+    """
+    if len(values) == 0:
+        return "empty"
+    if pd.api.types.is_bool_dtype(values):
+        return "string"
+    if pd.api.types.is_integer_dtype(values):
+        return "integer"
+    if pd.api.types.is_float_dtype(values) and all(math.isfinite(v) for v in values):
+        return "float"
+    return "string"
+
+def profile_column(name, column):
+    """
+    The profile of one column. Empty strings count as missing, as they do in the server's CSV reader
+    """
+    missing = column.isna()
+    if pd.api.types.is_object_dtype(column) or pd.api.types.is_string_dtype(column):
+        missing = missing | (column.astype(str).str.strip() == "")
+    values = column[~missing]
+    profile = {"name": str(name), "type": column_type(values), "nulls": int(missing.sum())}
+    if profile["type"] in ("integer", "float"):
+        profile["min"] = float(values.min())
+        profile["max"] = float(values.max())
+        profile["mean"] = float(values.mean())
+    counts = values.astype(str).value_counts()
+    if 0 < len(counts) <= MAX_CLASSES:
+        profile["class_balance"] = {str(value): int(count) for value, count in counts.items()}
+    return profile
+
+def profile(d):
+    """
+    The row count and a profile of every column of a dataset
+    """
+    return {"rows": len(d), "columns": [profile_column(name, d[name]) for name in d.columns]}